@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"github.com/1azunna/k8s-diff-tool/internal/loader"
+	"github.com/1azunna/k8s-diff-tool/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+// checkOptions holds the settings of the check subcommand.
+type checkOptions struct {
+	policyFile string
+	// failOn is the lowest severity that fails the run.
+	failOn string
+}
+
+// newCheckCommand creates the subcommand evaluating policy rules against the
+// changes between two manifest sets.
+func newCheckCommand(opts *cliOptions) *cobra.Command {
+	copts := &checkOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "check --policy FILE BEFORE AFTER",
+		Short: "Check the changes between two manifest sets against policy rules",
+		Long: `Pair the resources of BEFORE and AFTER (files or directories) by kind,
+namespace and name, and evaluate the rules of a policy file against the added,
+removed and modified resources. Findings are reported by severity; the run fails
+if any finding is at least as severe as --fail-on.
+
+Rules use a built-in check or a CEL expression over old, new and change:
+
+  rules:
+    - name: no-scale-to-zero
+      check: replicas-to-zero
+    - name: pinned-images
+      severity: warning
+      check: latest-image
+    - name: few-deletions
+      check: max-deletions
+      max: 5
+    - name: no-host-network
+      kinds: [deploy]
+      expr: new != null && has(new.spec.template.spec.hostNetwork) && new.spec.template.spec.hostNetwork
+      message: pods use the host network`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			diffOpts, err := opts.diffOptions()
+			if err != nil {
+				return err
+			}
+			return runCheck(cmd.OutOrStdout(), args[0], args[1], diffOpts, *copts)
+		},
+	}
+
+	cmd.Flags().StringVar(&copts.policyFile, "policy", "", "Policy file with the rules to check")
+	cmd.Flags().StringVar(&copts.failOn, "fail-on", string(policy.SeverityError), "Lowest finding severity that fails the run (info, warning or error)")
+	_ = cmd.MarkFlagRequired("policy")
+
+	return cmd
+}
+
+func runCheck(out io.Writer, pathA, pathB string, opts differ.Options, copts checkOptions) error {
+	failOn, err := policy.ParseSeverity(copts.failOn)
+	if err != nil {
+		return err
+	}
+	p, err := policy.Load(copts.policyFile)
+	if err != nil {
+		return err
+	}
+
+	dataA, err := loadManifests(pathA)
+	if err != nil {
+		return err
+	}
+	dataB, err := loadManifests(pathB)
+	if err != nil {
+		return err
+	}
+	changes, err := differ.Changes(dataA, dataB, opts)
+	if err != nil {
+		return err
+	}
+
+	findings, err := p.Evaluate(changes)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(out, "# No policy findings")
+		return nil
+	}
+
+	counts := make(map[policy.Severity]int)
+	failed := 0
+	fmt.Fprintln(out, "# Policy findings:")
+	for _, f := range findings {
+		counts[f.Severity]++
+		if f.Severity.AtLeast(failOn) {
+			failed++
+		}
+		if f.Kind == "" {
+			fmt.Fprintf(out, "#   [%s] %s: %s\n", f.Severity, f.Rule, f.Message)
+		} else {
+			fmt.Fprintf(out, "#   [%s] %s: [%s %s] %s\n", f.Severity, f.Rule, f.Kind, qualifiedName(f.Namespace, f.Name), f.Message)
+		}
+	}
+	fmt.Fprintf(out, "# %d errors, %d warnings, %d info\n", counts[policy.SeverityError], counts[policy.SeverityWarning], counts[policy.SeverityInfo])
+
+	if failed > 0 {
+		return &exitCodeError{
+			code: exitPolicy,
+			err:  fmt.Errorf("%d policy findings at or above %s severity", failed, failOn),
+		}
+	}
+	return nil
+}
+
+// loadManifests reads a manifest file, or the YAML files of a directory in
+// filename order as a single multi-document stream.
+func loadManifests(path string) ([]byte, error) {
+	isDir, err := loader.IsDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	if !isDir {
+		return loader.LoadFile(path)
+	}
+
+	files, err := loader.ListYAMLFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	for _, filename := range files {
+		data, err := loader.LoadFile(filepath.Join(path, filename))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", filepath.Join(path, filename), err)
+		}
+		buf.WriteString("\n---\n")
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,673 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"github.com/1azunna/k8s-diff-tool/internal/loader"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Diff strategies for cluster mode.
+const (
+	// diffStrategyServer compares live objects with a server-side dry-run apply.
+	diffStrategyServer = "server"
+	// diffStrategyClient compares live objects with local manifests directly.
+	diffStrategyClient = "client"
+	// diffStrategyAuto uses the server strategy, falling back to the client
+	// strategy per resource when dry-run apply is unavailable.
+	diffStrategyAuto = "auto"
+)
+
+// Cluster mode comparisons.
+const (
+	// modePredicted compares live objects with the state an apply would
+	// produce.
+	modePredicted = "predicted"
+	// modeIntent compares the fields local manifests declare with their live
+	// values, to detect drift such as hand edits.
+	modeIntent = "intent"
+)
+
+// clusterOptions holds the cluster mode settings.
+type clusterOptions struct {
+	config       cluster.Config
+	concurrency  int
+	mode         string
+	diffStrategy string
+	// fieldManager is the field manager dry-run applies are made as.
+	fieldManager string
+	// conflicts reports fields an apply would take over from other managers.
+	conflicts bool
+	// applySet is the ApplySet parent reference used for the prune preview.
+	applySet string
+	// pruneSelector is the label selector used for the prune preview when no
+	// ApplySet is given.
+	pruneSelector string
+}
+
+// clusterItem is a single local resource to compare with the cluster.
+type clusterItem struct {
+	filename string
+	local    *unstructured.Unstructured
+	// prune marks a live resource that is missing locally, so local holds the
+	// live object and it is shown as a deletion.
+	prune bool
+	// err is set if the file the resource would come from could not be read or parsed.
+	err error
+}
+
+// clusterResult is the rendered outcome of comparing one clusterItem.
+type clusterResult struct {
+	output string
+	err    error
+	// conflicted is set if applying would conflict with other field managers.
+	conflicted bool
+	// immutable is set if applying changes immutable fields.
+	immutable bool
+}
+
+func runClusterDiff(ctx context.Context, out io.Writer, path string, opts differ.Options, copts clusterOptions) error {
+	if err := copts.validate(); err != nil {
+		return err
+	}
+
+	client, err := cluster.NewClient(copts.config)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster client: %w", err)
+	}
+	return diffCluster(ctx, out, client, path, opts, copts)
+}
+
+// validate checks the cluster mode settings for invalid combinations.
+func (copts clusterOptions) validate() error {
+	switch copts.diffStrategy {
+	case diffStrategyServer, diffStrategyClient, diffStrategyAuto:
+	default:
+		return fmt.Errorf("invalid diff strategy %q: must be server, client or auto", copts.diffStrategy)
+	}
+	switch copts.mode {
+	case modePredicted, modeIntent:
+	default:
+		return fmt.Errorf("invalid mode %q: must be predicted or intent", copts.mode)
+	}
+	if copts.conflicts && (copts.mode != modePredicted || copts.diffStrategy == diffStrategyClient) {
+		return fmt.Errorf("--conflicts requires the predicted mode and a server-side diff strategy")
+	}
+	return nil
+}
+
+// diffCluster compares the local manifests at path, a file or directory,
+// with the cluster behind client and writes the diffs to out.
+func diffCluster(ctx context.Context, out io.Writer, client cluster.Backend, path string, opts differ.Options, copts clusterOptions) error {
+	isDir, err := loader.IsDir(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %s: %w", path, err)
+	}
+
+	// Kind filters may use short names only the cluster knows (e.g. of CRDs).
+	// The filter is also used to skip resources before any API calls are made.
+	opts.KindResolver = client.ResolveKind
+	filter, err := differ.NewFilter(opts)
+	if err != nil {
+		return err
+	}
+
+	var items []clusterItem
+	if isDir {
+		items, err = loadClusterDir(client, path, opts.Namespace, filter)
+		if err != nil {
+			return err
+		}
+	} else {
+		items = loadClusterFile(client, path, path, opts.Namespace, filter)
+	}
+
+	// Namespaces and CRDs created by this batch can't be relied on by the
+	// dry-run of the resources that depend on them.
+	deps := findBatchDependencies(items)
+	deps.resolveNamespaces(client, items, opts.Namespace)
+
+	if copts.applySet != "" || copts.pruneSelector != "" {
+		pruned, err := findPruneCandidates(ctx, client, items, opts, copts, filter)
+		if err != nil {
+			return err
+		}
+		items = append(items, pruned...)
+	}
+
+	return diffClusterItems(ctx, out, client, items, deps, opts, copts)
+}
+
+// findPruneCandidates lists the live resources that `kubectl apply --prune`
+// would delete: members of the ApplySet (or, without one, resources of the
+// local kinds matching the prune selector) that are missing locally.
+func findPruneCandidates(ctx context.Context, client cluster.Backend, items []clusterItem, opts differ.Options, copts clusterOptions, filter *differ.Filter) ([]clusterItem, error) {
+	local := make(map[string]bool)
+	var groupKinds []schema.GroupKind
+	seenGK := make(map[schema.GroupKind]bool)
+	var namespaces []string
+	seenNS := make(map[string]bool)
+
+	for _, item := range items {
+		if item.local == nil {
+			continue
+		}
+		key, namespace := pruneKey(client, item.local)
+		local[key] = true
+
+		gk := item.local.GroupVersionKind().GroupKind()
+		if !seenGK[gk] {
+			seenGK[gk] = true
+			groupKinds = append(groupKinds, gk)
+		}
+		if namespace != "" && !seenNS[namespace] {
+			seenNS[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	source, selector := "prune selector "+copts.pruneSelector, copts.pruneSelector
+	if copts.applySet != "" {
+		set, err := client.GetApplySet(ctx, copts.applySet, opts.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		source, selector = "applyset "+copts.applySet, set.Selector()
+		// As with kubectl, the namespaces of the applied manifests are
+		// searched too: a cluster-scoped parent has no namespace of its own,
+		// and additional-namespaces may not list them yet.
+		groupKinds = set.GroupKinds
+		for _, namespace := range set.Namespaces {
+			if !seenNS[namespace] {
+				seenNS[namespace] = true
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	} else if len(namespaces) == 0 {
+		namespaces = []string{client.DefaultNamespace()}
+	}
+
+	live, err := client.ListBySelector(ctx, groupKinds, namespaces, selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prune candidates: %w", err)
+	}
+
+	var pruned []clusterItem
+	for _, obj := range live {
+		if key, _ := pruneKey(client, obj); local[key] {
+			continue
+		}
+		matched, err := filter.Match(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		pruned = append(pruned, clusterItem{filename: source, local: obj, prune: true})
+	}
+	return pruned, nil
+}
+
+// pruneKey identifies a resource across local and live objects by GroupKind,
+// effective namespace and name. It also returns the effective namespace, which
+// is empty for cluster-scoped resources.
+func pruneKey(client cluster.Backend, obj *unstructured.Unstructured) (string, string) {
+	gvk := obj.GroupVersionKind()
+	namespace := obj.GetNamespace()
+	if namespaced, err := client.IsNamespaced(gvk); err == nil {
+		if !namespaced {
+			namespace = ""
+		} else if namespace == "" {
+			namespace = client.DefaultNamespace()
+		}
+	}
+	return gvk.GroupKind().String() + "/" + namespace + "/" + obj.GetName(), namespace
+}
+
+// loadClusterDir parses every YAML file in dir, in filename order.
+func loadClusterDir(client cluster.Backend, dir, namespace string, filter *differ.Filter) ([]clusterItem, error) {
+	files, err := loader.ListYAMLFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var items []clusterItem
+	for _, filename := range files {
+		items = append(items, loadClusterFile(client, filepath.Join(dir, filename), filename, namespace, filter)...)
+	}
+	return items, nil
+}
+
+// loadClusterFile parses the resources in a file, resolves their namespaces
+// (see cluster.Client.ResolveNamespace) and keeps those that pass the filter.
+// A read or parse failure is returned as a single failed item, and a namespace
+// conflict as a failed item for that resource, so they are reported alongside
+// the other results instead of aborting the run.
+func loadClusterFile(client cluster.Backend, path, filename, namespace string, filter *differ.Filter) []clusterItem {
+	data, err := loader.LoadFile(path)
+	if err != nil {
+		return []clusterItem{{filename: filename, err: fmt.Errorf("error reading %s: %w", path, err)}}
+	}
+
+	resources, err := cluster.ParseResources(data)
+	if err != nil {
+		return []clusterItem{{filename: filename, err: fmt.Errorf("failed to parse %s: %w", filename, err)}}
+	}
+
+	var items []clusterItem
+	for _, res := range resources {
+		// Resolve the namespace once, so the header, the lookup and the
+		// dry-run all agree on it.
+		resolved, err := client.ResolveNamespace(res, namespace)
+		switch {
+		case errors.Is(err, cluster.ErrNamespaceMismatch):
+			items = append(items, clusterItem{filename: filename, local: res, err: err})
+			continue
+		case err == nil:
+			res.SetNamespace(resolved)
+		}
+
+		matched, err := filter.Match(res.Object)
+		if err != nil || matched {
+			items = append(items, clusterItem{filename: filename, local: res, err: err})
+		}
+	}
+	return items
+}
+
+// diffClusterItems compares items with the cluster using up to concurrency
+// workers. Output is printed in input order as soon as it is ready, and
+// per-resource errors are collected rather than aborting the run.
+func diffClusterItems(ctx context.Context, out io.Writer, client cluster.Backend, items []clusterItem, deps *batchDependencies, opts differ.Options, copts clusterOptions) error {
+	concurrency := copts.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]clusterResult, len(items))
+	done := make([]chan struct{}, len(items))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = diffClusterItem(ctx, client, items[i], deps, opts, copts)
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range items {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var errs []error
+	conflicted, immutable := 0, 0
+	for i := range items {
+		select {
+		case <-done[i]:
+		case <-ctx.Done():
+			// Undispatched items never finish; in-flight requests are cancelled.
+			wg.Wait()
+			return ctx.Err()
+		}
+		if results[i].conflicted {
+			conflicted++
+		}
+		if results[i].immutable {
+			immutable++
+		}
+		if err := results[i].err; err != nil {
+			if opts.SecureMode {
+				err = redactError(err)
+			}
+			fmt.Fprintf(out, "# Error for %s: %v\n", describeItem(items[i]), err)
+			fmt.Fprintln(out, "# --------------------------------------------------")
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprint(out, results[i].output)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d resources failed: %w", len(errs), len(items), errors.Join(errs...))
+	}
+	if immutable > 0 {
+		return errImmutable(immutable)
+	}
+	if conflicted > 0 {
+		return fmt.Errorf("%d of %d resources conflict with other field managers", conflicted, len(items))
+	}
+	return nil
+}
+
+// redactError replaces an API error with its status reason and code, as
+// server messages can quote field values, such as Secret data rejected by a
+// webhook. Secure mode reports errors this way; other errors are local and
+// returned as they are.
+func redactError(err error) error {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return err
+	}
+	return fmt.Errorf("request failed (%s); the server's message is withheld in secure mode", cluster.ErrorStatus(err))
+}
+
+// describeItem names an item for error reporting.
+func describeItem(item clusterItem) string {
+	if item.local == nil {
+		return item.filename
+	}
+	return fmt.Sprintf("%s [%s %s]", item.filename, item.local.GetKind(), qualifiedName(item.local.GetNamespace(), item.local.GetName()))
+}
+
+// qualifiedName formats namespace/name, or just name for cluster-scoped resources.
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// diffClusterItem compares one local resource with its live counterpart and
+// renders the result. Resources depending on Namespaces or CRDs that deps says
+// are created in the same batch are rendered as pending creations.
+func diffClusterItem(ctx context.Context, client cluster.Backend, item clusterItem, deps *batchDependencies, opts differ.Options, copts clusterOptions) clusterResult {
+	if item.err != nil {
+		return clusterResult{err: item.err}
+	}
+	if item.prune {
+		return renderPrune(item, opts)
+	}
+
+	localRes := item.local
+	gvk := localRes.GroupVersionKind()
+	name := localRes.GetName()
+	// Namespace was resolved when loading; it is empty for cluster-scoped resources.
+	namespace := localRes.GetNamespace()
+
+	// 1. Fetch live resource (current state)
+	liveRes, err := client.GetResource(ctx, gvk.GroupVersion().String(), gvk.Kind, name, namespace)
+	if err != nil && !apierrors.IsNotFound(err) {
+		if reason := deps.pendingReason(localRes, meta.IsNoMatchError(err), false); reason != "" {
+			return renderPending(item, reason, opts)
+		}
+		return clusterResult{err: fmt.Errorf("failed to fetch resource %s: %w", qualifiedName(namespace, name), err)}
+	}
+
+	// 2. Compute the target state to compare against
+	var liveBytes, targetBytes []byte
+	var note string
+	var conflicts []cluster.FieldConflict
+	var owners []cluster.FieldOwners
+	// foreign are the declared fields intent mode leaves out.
+	var foreign []cluster.FieldOwners
+	var immutable []differ.ImmutableChange
+	// serverTarget is set if targetBytes is the server's prediction.
+	serverTarget := false
+	strategy := copts.diffStrategy
+	if copts.mode == modeIntent {
+		// Intent mode compares declared fields only and never dry-runs.
+		liveBytes, targetBytes, foreign, err = ownedIntentTarget(liveRes, localRes, copts.fieldManager)
+		if err != nil {
+			return clusterResult{err: err}
+		}
+		strategy = ""
+	}
+	if strategy == diffStrategyServer || strategy == diffStrategyAuto {
+		var res serverSideResult
+		res, err = serverSideTarget(ctx, client, liveRes, localRes, copts)
+		liveBytes, targetBytes = res.live, res.target
+		conflicts, owners = res.conflicts, res.owners
+		if err != nil && liveRes == nil {
+			if reason := deps.pendingReason(localRes, meta.IsNoMatchError(err), apierrors.IsNotFound(err)); reason != "" {
+				return renderPending(item, reason, opts)
+			}
+		}
+		rejections, rejected := cluster.ImmutableRejections(err)
+		switch {
+		case err == nil:
+			serverTarget = true
+		case rejected && liveRes != nil:
+			// The error is left out, as it may quote secret values.
+			note = "the server rejects this apply, showing a client-side diff"
+			for _, r := range rejections {
+				reason := r.Reason
+				if opts.SecureMode {
+					// Messages vary by validator, so a value may survive
+					// the stripping; only the failure type is shown.
+					reason = string(r.Type)
+				}
+				immutable = append(immutable, differ.ImmutableChange{Kind: gvk.Kind, Namespace: namespace, Name: name, Field: r.Field, Reason: reason})
+			}
+			strategy = diffStrategyClient
+		case strategy != diffStrategyAuto || !cluster.IsDryRunUnavailable(err):
+			return clusterResult{err: err}
+		default:
+			// Only the status is shown: the message may quote secret values.
+			note = fmt.Sprintf("server-side dry-run unavailable (%s), falling back to client-side diff", cluster.ErrorStatus(err))
+			strategy = diffStrategyClient
+		}
+	}
+	if strategy == diffStrategyClient {
+		liveBytes, targetBytes = clientSideTarget(liveRes, localRes)
+	}
+
+	diff, err := differ.Diff(liveBytes, targetBytes, opts)
+	if err != nil {
+		return clusterResult{err: err}
+	}
+
+	if immutable == nil && liveRes != nil {
+		// A direct comparison would flag fields the server fills in, such as
+		// the defaults and labels of a Job's pod template, so only the fields
+		// the local object declares are checked.
+		checkLive, checkTarget := liveBytes, targetBytes
+		if !serverTarget {
+			checkLive, checkTarget = intentTarget(liveRes, localRes)
+		}
+		immutable, err = differ.ImmutableChanges(checkLive, checkTarget, differ.Options{})
+		if err != nil {
+			return clusterResult{err: err}
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Diff for %s (Cluster vs Local) [%s %s]:\n", item.filename, gvk.Kind, qualifiedName(namespace, name))
+	if note != "" {
+		fmt.Fprintf(&out, "# Note: %s\n", note)
+	}
+	fmt.Fprintln(&out, diff)
+	writeImmutableWarning(&out, immutable, false)
+	if len(conflicts) > 0 {
+		fmt.Fprintln(&out, "# Conflicts: applying takes these fields over from other managers (requires --force-conflicts):")
+		for _, c := range conflicts {
+			fmt.Fprintf(&out, "#   %s: %s\n", c.Field, c.Manager)
+		}
+	}
+	if len(foreign) > 0 {
+		fmt.Fprintf(&out, "# Not compared, owned by managers other than %s:\n", copts.fieldManager)
+		for _, o := range foreign {
+			fmt.Fprintf(&out, "#   %s: %s\n", o.Field, strings.Join(o.Managers, ", "))
+		}
+	}
+	if len(owners) > 0 {
+		fmt.Fprintln(&out, "# Current owners of changed fields:")
+		for _, o := range owners {
+			fmt.Fprintf(&out, "#   %s: %s\n", o.Field, strings.Join(o.Managers, ", "))
+		}
+	}
+	fmt.Fprintln(&out, "# --------------------------------------------------")
+	return clusterResult{output: out.String(), conflicted: len(conflicts) > 0, immutable: len(immutable) > 0}
+}
+
+// serverSideResult is the outcome of a server-side dry-run apply.
+type serverSideResult struct {
+	live, target []byte
+	// conflicts are the fields an apply without force would conflict on.
+	// Only set in conflicts mode.
+	conflicts []cluster.FieldConflict
+	// owners are the changed fields with the managers owning them today.
+	// Only set in conflicts mode.
+	owners []cluster.FieldOwners
+}
+
+// serverSideTarget renders the live object and the predicted result of a
+// server-side dry-run apply of the local object. In conflicts mode, it first
+// applies without force to find the fields owned by other managers.
+func serverSideTarget(ctx context.Context, client cluster.Backend, liveRes, localRes *unstructured.Unstructured, copts clusterOptions) (serverSideResult, error) {
+	var result serverSideResult
+
+	// If liveRes is missing, SSA Dry-Run will show the Creation result (defaults applied).
+	// If liveRes exists, SSA Dry-Run will show the Merged result.
+	var dryRunRes *unstructured.Unstructured
+	var err error
+	if copts.conflicts {
+		dryRunRes, err = client.ServerSideApplyDryRun(ctx, localRes, copts.fieldManager, false)
+		if conflicts, ok := cluster.ApplyConflicts(err); ok {
+			result.conflicts = conflicts
+			dryRunRes = nil
+		} else if err != nil {
+			return result, fmt.Errorf("failed to server-side dry-run apply: %w", err)
+		}
+	}
+	if dryRunRes == nil {
+		// Force ownership to see the result despite conflicts
+		dryRunRes, err = client.ServerSideApplyDryRun(ctx, localRes, copts.fieldManager, true)
+		if err != nil {
+			return result, fmt.Errorf("failed to server-side dry-run apply: %w", err)
+		}
+	}
+
+	if copts.conflicts && liveRes != nil {
+		// Ownership is read before managedFields is stripped below.
+		result.owners, err = cluster.ChangedFieldOwners(liveRes, dryRunRes)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// We diff Live vs DryRun rather than Live vs Local, so the target is
+	// "what the object will look like after apply", with server defaults.
+	// (Live vs Local, restricted to the declared fields, is intent mode.)
+	//    - If Live missing: Diff Empty vs DryRun (Creation).
+	//    - If Live exists: Diff Live vs DryRun (Update).
+	if liveRes != nil {
+		// The DryRun result contains the same server metadata (creationTimestamp, uid, ...)
+		// as the Live object, so those fields match. managedFields changes on
+		// every SSA though, so strip it from BOTH to avoid noise.
+		unstructured.RemoveNestedField(liveRes.Object, "metadata", "managedFields")
+		result.live, _ = yaml.Marshal(liveRes.Object)
+	}
+
+	// We also strip managedFields because our manager entry may be new.
+	unstructured.RemoveNestedField(dryRunRes.Object, "metadata", "managedFields")
+	result.target, _ = yaml.Marshal(dryRunRes.Object)
+	return result, nil
+}
+
+// clientSideTarget renders the live and local objects for a direct comparison.
+// Server-populated fields are stripped from both, since the server never
+// processes the local object.
+func clientSideTarget(liveRes, localRes *unstructured.Unstructured) ([]byte, []byte) {
+	var liveBytes []byte
+	if liveRes != nil {
+		liveBytes, _ = yaml.Marshal(cluster.Normalize(liveRes).Object)
+	}
+	localBytes, _ := yaml.Marshal(cluster.Normalize(localRes).Object)
+	return liveBytes, localBytes
+}
+
+// intentTarget renders the live values of the fields the local object
+// declares, and the local object. Fields the local object doesn't declare,
+// such as server defaults or fields set by controllers, are left out.
+func intentTarget(liveRes, localRes *unstructured.Unstructured) ([]byte, []byte) {
+	localRes = cluster.Normalize(localRes)
+	var liveBytes []byte
+	if liveRes != nil {
+		liveBytes, _ = yaml.Marshal(cluster.ProjectOnto(liveRes, localRes).Object)
+	}
+	localBytes, _ := yaml.Marshal(localRes.Object)
+	return liveBytes, localBytes
+}
+
+// ownedIntentTarget is intentTarget leaving out the declared fields that
+// other managers own and fieldManager doesn't, which it returns.
+func ownedIntentTarget(liveRes, localRes *unstructured.Unstructured, fieldManager string) ([]byte, []byte, []cluster.FieldOwners, error) {
+	if liveRes == nil {
+		liveBytes, localBytes := intentTarget(nil, localRes)
+		return liveBytes, localBytes, nil, nil
+	}
+	projected, declared, foreign, err := cluster.ProjectOwned(liveRes, cluster.Normalize(localRes), fieldManager)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	liveBytes, _ := yaml.Marshal(projected.Object)
+	localBytes, _ := yaml.Marshal(declared.Object)
+	return liveBytes, localBytes, foreign, nil
+}
+
+// renderPending renders a resource that can't be dry-run applied until its
+// dependencies in the batch exist, as a creation of its local content.
+func renderPending(item clusterItem, reason string, opts differ.Options) clusterResult {
+	localRes := cluster.Normalize(item.local)
+	localBytes, _ := yaml.Marshal(localRes.Object)
+
+	diff, err := differ.Diff(nil, localBytes, opts)
+	if err != nil {
+		return clusterResult{err: err}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Diff for %s (Cluster vs Local) [%s %s]:\n", item.filename, localRes.GetKind(), qualifiedName(localRes.GetNamespace(), localRes.GetName()))
+	fmt.Fprintf(&out, "# Note: %s\n", reason)
+	fmt.Fprintln(&out, diff)
+	fmt.Fprintln(&out, "# --------------------------------------------------")
+	return clusterResult{output: out.String()}
+}
+
+// renderPrune renders a live resource that is missing locally as a deletion.
+func renderPrune(item clusterItem, opts differ.Options) clusterResult {
+	liveRes := item.local.DeepCopy()
+	unstructured.RemoveNestedField(liveRes.Object, "metadata", "managedFields")
+	liveBytes, _ := yaml.Marshal(liveRes.Object)
+
+	diff, err := differ.Diff(liveBytes, nil, opts)
+	if err != nil {
+		return clusterResult{err: err}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Prune from %s (will be deleted) [%s %s]:\n", item.filename, liveRes.GetKind(), qualifiedName(liveRes.GetNamespace(), liveRes.GetName()))
+	fmt.Fprintln(&out, diff)
+	fmt.Fprintln(&out, "# --------------------------------------------------")
+	return clusterResult{output: out.String()}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"github.com/1azunna/k8s-diff-tool/internal/loader"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// compareOptions holds the settings of the cluster subcommand.
+type compareOptions struct {
+	// config is the connection configuration shared by both clusters.
+	config      cluster.Config
+	fromContext string
+	toContext   string
+	// filenames are local manifests naming the resources to compare. Without
+	// them, resources are listed by the --include kinds.
+	filenames []string
+	allNS     bool
+}
+
+// comparePair is one resource as found in each of the two clusters.
+type comparePair struct {
+	kind      string
+	namespace string
+	name      string
+	from, to  *unstructured.Unstructured
+	err       error
+}
+
+// newClusterCommand creates the subcommand comparing two live clusters.
+func newClusterCommand(opts *cliOptions) *cobra.Command {
+	copts := &compareOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "cluster --from-context CONTEXT --to-context CONTEXT",
+		Short: "Compare the same resources in two clusters",
+		Long: `Fetch the same set of resources from two clusters and diff them.
+
+Resources are either listed by kind (--include), in the --namespace (or all
+namespaces with -A) and matching the --selector, or named by local manifests
+(--filename). Fields populated or assigned by each cluster are ignored.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// One server and token can't be right for two clusters.
+			if opts.server != "" || opts.token != "" {
+				return fmt.Errorf("--server and --token would apply to both clusters; set them in the kubeconfig contexts instead")
+			}
+			diffOpts, err := opts.diffOptions()
+			if err != nil {
+				return err
+			}
+			copts.config = opts.clusterConfig("")
+			return runClusterCompare(cmd.Context(), cmd.OutOrStdout(), diffOpts, *copts)
+		},
+	}
+
+	cmd.Flags().StringVar(&copts.fromContext, "from-context", "", "Kubernetes context of the cluster to compare from")
+	cmd.Flags().StringVar(&copts.toContext, "to-context", "", "Kubernetes context of the cluster to compare to")
+	cmd.Flags().StringSliceVarP(&copts.filenames, "filename", "f", nil, "Files or directories whose resources are compared (instead of listing --include kinds)")
+	addAllNamespacesFlag(cmd, &copts.allNS)
+	_ = cmd.MarkFlagRequired("from-context")
+	_ = cmd.MarkFlagRequired("to-context")
+
+	return cmd
+}
+
+func runClusterCompare(ctx context.Context, out io.Writer, opts differ.Options, copts compareOptions) error {
+	if len(copts.filenames) == 0 && len(opts.IncludeKinds) == 0 {
+		return fmt.Errorf("select the resources to compare with --include or --filename")
+	}
+
+	fromConfig, toConfig := copts.config, copts.config
+	fromConfig.Context, toConfig.Context = copts.fromContext, copts.toContext
+	from, err := cluster.NewClient(fromConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster client for %s: %w", copts.fromContext, err)
+	}
+	to, err := cluster.NewClient(toConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster client for %s: %w", copts.toContext, err)
+	}
+	return compareClusters(ctx, out, from, to, opts, copts)
+}
+
+// compareClusters diffs the selected resources between two clusters, printing
+// per-resource errors alongside the diffs.
+func compareClusters(ctx context.Context, out io.Writer, from, to cluster.Backend, opts differ.Options, copts compareOptions) error {
+	opts.KindResolver = from.ResolveKind
+	filter, err := differ.NewFilter(opts)
+	if err != nil {
+		return err
+	}
+
+	var pairs []comparePair
+	if len(copts.filenames) > 0 {
+		pairs, err = fetchManifestPairs(ctx, from, to, copts.filenames, opts.Namespace, filter)
+	} else {
+		pairs, err = listPairs(ctx, from, to, opts, copts.allNS, filter)
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pair := range pairs {
+		output, err := renderComparePair(pair, copts, opts)
+		if err != nil {
+			if opts.SecureMode {
+				err = redactError(err)
+			}
+			fmt.Fprintf(out, "# Error for [%s %s]: %v\n", pair.kind, qualifiedName(pair.namespace, pair.name), err)
+			fmt.Fprintln(out, "# --------------------------------------------------")
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprint(out, output)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d resources failed: %w", len(errs), len(pairs), errors.Join(errs...))
+	}
+	return nil
+}
+
+// fetchManifestPairs fetches the resources declared in the local manifests
+// from both clusters. Namespaces are resolved against the from cluster.
+func fetchManifestPairs(ctx context.Context, from, to cluster.Backend, paths []string, namespace string, filter *differ.Filter) ([]comparePair, error) {
+	var items []clusterItem
+	for _, path := range paths {
+		isDir, err := loader.IsDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %s: %w", path, err)
+		}
+		if isDir {
+			dirItems, err := loadClusterDir(from, path, namespace, filter)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, dirItems...)
+		} else {
+			items = append(items, loadClusterFile(from, path, path, namespace, filter)...)
+		}
+	}
+
+	var pairs []comparePair
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if item.local == nil {
+			pairs = append(pairs, comparePair{kind: "file", name: item.filename, err: item.err})
+			continue
+		}
+		gvk := item.local.GroupVersionKind()
+		pair := comparePair{kind: gvk.Kind, namespace: item.local.GetNamespace(), name: item.local.GetName(), err: item.err}
+		if pair.err == nil {
+			pair.from, pair.err = getIfExists(ctx, from, item.local)
+		}
+		if pair.err == nil {
+			pair.to, pair.err = getIfExists(ctx, to, item.local)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// getIfExists fetches the live counterpart of obj, or nil if it doesn't exist.
+func getIfExists(ctx context.Context, client cluster.Backend, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	live, err := client.GetResource(ctx, obj.GetAPIVersion(), obj.GetKind(), obj.GetName(), obj.GetNamespace())
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch resource %s: %w", qualifiedName(obj.GetNamespace(), obj.GetName()), err)
+	}
+	return live, nil
+}
+
+// listPairs lists the --include kinds in both clusters and pairs the results
+// by kind, namespace and name. Without --namespace or -A, the from cluster's
+// default namespace is used for both. Like the differ's filters, a pair is
+// kept if either side passes the filter, so a resource whose labels or
+// fields drifted between the clusters is compared rather than reported as
+// missing from one.
+func listPairs(ctx context.Context, from, to cluster.Backend, opts differ.Options, allNamespaces bool, filter *differ.Filter) ([]comparePair, error) {
+	groupKinds, err := resolveGroupKinds(from, opts.IncludeKinds)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := listNamespaces(from, opts.Namespace, allNamespaces)
+
+	fromObjs, err := from.ListBySelector(ctx, groupKinds, namespaces, opts.Selector)
+	if err != nil {
+		return nil, err
+	}
+	toObjs, err := to.ListBySelector(ctx, groupKinds, namespaces, opts.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*comparePair)
+	pairOf := func(obj *unstructured.Unstructured) *comparePair {
+		key := obj.GroupVersionKind().GroupKind().String() + "/" + obj.GetNamespace() + "/" + obj.GetName()
+		pair, ok := byKey[key]
+		if !ok {
+			pair = &comparePair{kind: obj.GetKind(), namespace: obj.GetNamespace(), name: obj.GetName()}
+			byKey[key] = pair
+		}
+		return pair
+	}
+	for _, obj := range fromObjs {
+		pairOf(obj).from = obj
+	}
+	for _, obj := range toObjs {
+		pairOf(obj).to = obj
+	}
+
+	pairs := make([]comparePair, 0, len(byKey))
+	for _, pair := range byKey {
+		// Each cluster applied the selector itself, so a resource whose
+		// labels drifted is only listed by one; fetch its counterpart.
+		if opts.Selector != "" {
+			if pair.from == nil {
+				pair.from, pair.err = getIfExists(ctx, from, pair.to)
+			} else if pair.to == nil {
+				pair.to, pair.err = getIfExists(ctx, to, pair.from)
+			}
+		}
+		if pair.err == nil {
+			var matched bool
+			matched, pair.err = matchesEither(filter, pair.from, pair.to)
+			if pair.err == nil && !matched {
+				continue
+			}
+		}
+		pairs = append(pairs, *pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		return a.name < b.name
+	})
+	return pairs, nil
+}
+
+// matchesEither reports whether any of the objects that exist passes the
+// filter.
+func matchesEither(filter *differ.Filter, objs ...*unstructured.Unstructured) (bool, error) {
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+		matched, err := filter.Match(obj.Object)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// resolveGroupKinds resolves --include tokens to the GroupKinds the cluster
+// serves, so they can be listed.
+func resolveGroupKinds(client cluster.Backend, tokens []string) ([]schema.GroupKind, error) {
+	var groupKinds []schema.GroupKind
+	for _, token := range tokens {
+		t, err := differ.ParseKindToken(token)
+		if err != nil {
+			return nil, err
+		}
+		gk, ok := client.ResolveKind(schema.GroupResource{Group: t.Group, Resource: strings.ToLower(t.Name)})
+		if !ok {
+			return nil, fmt.Errorf("the server doesn't have a resource type %q", token)
+		}
+		groupKinds = append(groupKinds, gk)
+	}
+	return groupKinds, nil
+}
+
+// listNamespaces returns the namespaces to list resources in: the given
+// namespace, all namespaces, or the kubeconfig namespace.
+func listNamespaces(client cluster.Backend, namespace string, allNamespaces bool) []string {
+	switch {
+	case namespace != "":
+		return []string{namespace}
+	case allNamespaces:
+		// The empty namespace lists across all namespaces.
+		return []string{""}
+	default:
+		return []string{client.DefaultNamespace()}
+	}
+}
+
+// renderComparePair diffs a resource between the two clusters, ignoring
+// server-populated and cluster-assigned fields.
+func renderComparePair(pair comparePair, copts compareOptions, opts differ.Options) (string, error) {
+	if pair.err != nil {
+		return "", pair.err
+	}
+
+	var fromBytes, toBytes []byte
+	if pair.from != nil {
+		fromBytes, _ = yaml.Marshal(cluster.NormalizeAcrossClusters(pair.from).Object)
+	}
+	if pair.to != nil {
+		toBytes, _ = yaml.Marshal(cluster.NormalizeAcrossClusters(pair.to).Object)
+	}
+
+	diff, err := differ.Diff(fromBytes, toBytes, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Diff for [%s %s] (%s vs %s):\n", pair.kind, qualifiedName(pair.namespace, pair.name), copts.fromContext, copts.toContext)
+	switch {
+	case pair.from == nil && pair.to == nil:
+		fmt.Fprintln(&out, "# Note: missing from both clusters")
+	case pair.from == nil:
+		fmt.Fprintf(&out, "# Note: only in %s\n", copts.toContext)
+	case pair.to == nil:
+		fmt.Fprintf(&out, "# Note: only in %s\n", copts.fromContext)
+	}
+	fmt.Fprintln(&out, diff)
+	fmt.Fprintln(&out, "# --------------------------------------------------")
+	return out.String(), nil
+}
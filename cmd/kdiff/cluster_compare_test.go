@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+)
+
+// runFakeCompare compares the resources of two fakes.
+func runFakeCompare(t *testing.T, from, to cluster.Backend, opts differ.Options) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	err := compareClusters(context.Background(), &out, from, to, opts, compareOptions{fromContext: "staging", toContext: "prod"})
+	return out.String(), err
+}
+
+func TestCompareClustersPairs(t *testing.T) {
+	from := cluster.NewFake("default", nil,
+		configMap("default", "shared", nil, map[string]interface{}{"mode": "slow"}),
+		configMap("default", "old", nil, map[string]interface{}{"k": "v"}),
+		configMap("other", "elsewhere", nil, map[string]interface{}{"k": "v"}),
+	)
+	to := cluster.NewFake("default", nil,
+		configMap("default", "shared", nil, map[string]interface{}{"mode": "fast"}),
+		configMap("default", "new", nil, map[string]interface{}{"k": "v"}),
+	)
+
+	out, err := runFakeCompare(t, from, to, differ.Options{IncludeKinds: []string{"configmaps"}})
+	if err != nil {
+		t.Fatalf("compareClusters() error = %v", err)
+	}
+
+	var headers []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "# Diff for") || strings.HasPrefix(line, "# Note") {
+			headers = append(headers, line)
+		}
+	}
+	want := []string{
+		"# Diff for [ConfigMap default/new] (staging vs prod):",
+		"# Note: only in prod",
+		"# Diff for [ConfigMap default/old] (staging vs prod):",
+		"# Note: only in staging",
+		"# Diff for [ConfigMap default/shared] (staging vs prod):",
+	}
+	if strings.Join(headers, "\n") != strings.Join(want, "\n") {
+		t.Errorf("compareClusters() headers =\n%s\nwant\n%s", strings.Join(headers, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(out, "-  mode: slow") || !strings.Contains(out, "+  mode: fast") {
+		t.Errorf("compareClusters() output missing the shared diff:\n%s", out)
+	}
+}
+
+func TestCompareClustersFiltersPairs(t *testing.T) {
+	// The label and the data drifted between the clusters, so each filter
+	// matches on one side only.
+	from := cluster.NewFake("default", nil,
+		configMap("default", "app", map[string]interface{}{"team": "web"}, map[string]interface{}{"mode": "fast"}),
+		configMap("default", "unrelated", map[string]interface{}{"team": "db"}, map[string]interface{}{"mode": "slow"}),
+	)
+	to := cluster.NewFake("default", nil,
+		configMap("default", "app", map[string]interface{}{"team": "api"}, map[string]interface{}{"mode": "slow"}),
+		configMap("default", "unrelated", map[string]interface{}{"team": "db"}, map[string]interface{}{"mode": "slow"}),
+	)
+
+	for name, opts := range map[string]differ.Options{
+		"selector in from": {IncludeKinds: []string{"configmaps"}, Selector: "team=web"},
+		"selector in to":   {IncludeKinds: []string{"configmaps"}, Selector: "team=api"},
+		"where":            {IncludeKinds: []string{"configmaps"}, Where: `object.data.mode == "fast"`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			out, err := runFakeCompare(t, from, to, opts)
+			if err != nil {
+				t.Fatalf("compareClusters() error = %v", err)
+			}
+			if strings.Contains(out, "only in") || strings.Contains(out, "unrelated") {
+				t.Errorf("compareClusters() reported the filtered pair as one-sided or kept others:\n%s", out)
+			}
+			if !strings.Contains(out, "# Diff for [ConfigMap default/app]") || !strings.Contains(out, "+  mode: slow") {
+				t.Errorf("compareClusters() output missing the drifted pair:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestCompareClustersErrors(t *testing.T) {
+	from := cluster.NewFake("default", nil,
+		configMap("default", "app", nil, map[string]interface{}{"mode": "fast"}),
+	)
+	to := cluster.NewFake("default", nil)
+
+	// ConfigMaps have no spec, so the expression fails on the resource.
+	out, err := runFakeCompare(t, from, to, differ.Options{IncludeKinds: []string{"configmaps"}, Where: "object.spec.replicas > 1"})
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 resources failed") {
+		t.Errorf("compareClusters() error = %v, want the failed resource", err)
+	}
+	if !strings.Contains(out, "# Error for [ConfigMap default/app]:") {
+		t.Errorf("compareClusters() output missing the error:\n%s", out)
+	}
+
+	if _, err := runFakeCompare(t, from, to, differ.Options{IncludeKinds: []string{"widgets"}}); err == nil {
+		t.Error("compareClusters() with an unknown kind succeeded, want an error")
+	}
+}
+
+func TestClusterCommandRejectsServer(t *testing.T) {
+	cmd := Entrypoint()
+	cmd.SetArgs([]string{"cluster", "--from-context", "staging", "--to-context", "prod", "-i", "cm", "--server", "https://example.com"})
+	cmd.SetOut(new(strings.Builder))
+	cmd.SetErr(new(strings.Builder))
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--server and --token") {
+		t.Errorf("Execute() error = %v, want --server rejected", err)
+	}
+}
+
+func TestAllNamespacesFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "file mode", args: []string{"-A", "a.yaml", "b.yaml"}, want: "unknown shorthand flag: 'A'"},
+		{name: "cluster mode", args: []string{"-c", "-A", "a.yaml"}, want: "unknown shorthand flag: 'A'"},
+		{name: "with namespace", args: []string{"snapshot", "-i", "cm", "-o", t.TempDir(), "-n", "prod", "-A"}, want: "[namespace all-namespaces] are set none of the others can be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := Entrypoint()
+			cmd.SetArgs(tt.args)
+			cmd.SetOut(new(strings.Builder))
+			cmd.SetErr(new(strings.Builder))
+			if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Execute() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
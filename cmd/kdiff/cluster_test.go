@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// object builds an unstructured object from a map.
+func object(obj map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func namespaceObject(name string) *unstructured.Unstructured {
+	return object(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": name},
+	})
+}
+
+func configMap(namespace, name string, labels, data map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name, "namespace": namespace}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	return object(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"data":       data,
+	})
+}
+
+// runFakeClusterDiff writes manifest to a file and diffs it against fake.
+func runFakeClusterDiff(t *testing.T, fake cluster.Backend, manifest string, opts differ.Options, copts clusterOptions) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if copts.mode == "" {
+		copts.mode = modePredicted
+	}
+	if copts.diffStrategy == "" {
+		copts.diffStrategy = diffStrategyServer
+	}
+	if err := copts.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+
+	var out strings.Builder
+	err := diffCluster(context.Background(), &out, fake, path, opts, copts)
+	return out.String(), err
+}
+
+func TestDiffClusterUpdate(t *testing.T) {
+	fake := cluster.NewFake("default", nil,
+		namespaceObject("default"),
+		configMap("default", "app", nil, map[string]interface{}{"mode": "old"}),
+	)
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  mode: new
+`, differ.Options{}, clusterOptions{})
+	if err != nil {
+		t.Fatalf("diffCluster() error = %v", err)
+	}
+	for _, want := range []string{"[ConfigMap default/app]", "-  mode: old", "+  mode: new"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diffCluster() output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+// orderingBackend makes the dry-run of one resource wait until three others
+// finished, so results complete out of input order, and fails others.
+type orderingBackend struct {
+	cluster.Backend
+	slow     string
+	failing  map[string]bool
+	finished chan string
+}
+
+func (b *orderingBackend) ServerSideApplyDryRun(ctx context.Context, local *unstructured.Unstructured, fieldManager string, force bool) (*unstructured.Unstructured, error) {
+	if local.GetName() == b.slow {
+		for i := 0; i < 3; i++ {
+			<-b.finished
+		}
+		return b.Backend.ServerSideApplyDryRun(ctx, local, fieldManager, force)
+	}
+	defer func() { b.finished <- local.GetName() }()
+	if b.failing[local.GetName()] {
+		return nil, apierrors.NewBadRequest("rejected " + local.GetName())
+	}
+	return b.Backend.ServerSideApplyDryRun(ctx, local, fieldManager, force)
+}
+
+func TestDiffClusterConcurrentOrder(t *testing.T) {
+	const count = 12
+	var manifest strings.Builder
+	backend := &orderingBackend{
+		Backend:  cluster.NewFake("default", nil, namespaceObject("default")),
+		slow:     "cm-00",
+		failing:  map[string]bool{"cm-04": true, "cm-09": true},
+		finished: make(chan string, count),
+	}
+	var want []string
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("cm-%02d", i)
+		fmt.Fprintf(&manifest, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\ndata:\n  k: v\n", name)
+		heading := "# Diff for"
+		if backend.failing[name] {
+			heading = "# Error for"
+		}
+		want = append(want, heading+" [ConfigMap default/"+name+"]")
+	}
+
+	out, err := runFakeClusterDiff(t, backend, manifest.String(), differ.Options{}, clusterOptions{concurrency: 4})
+	if err == nil || !strings.Contains(err.Error(), "2 of 12 resources failed") {
+		t.Fatalf("diffCluster() error = %v, want 2 of 12 resources failed", err)
+	}
+	for _, name := range []string{"cm-04", "cm-09"} {
+		if !strings.Contains(err.Error(), "rejected "+name) {
+			t.Errorf("diffCluster() error = %v, want the error of %s", err, name)
+		}
+	}
+
+	var got []string
+	for _, line := range strings.Split(out, "\n") {
+		for _, heading := range []string{"# Diff for", "# Error for"} {
+			if strings.HasPrefix(line, heading) {
+				got = append(got, heading+" "+line[strings.Index(line, "["):strings.Index(line, "]")+1])
+			}
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diffCluster() resource order =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiffClusterPendingNamespace(t *testing.T) {
+	fake := cluster.NewFake("default", nil, namespaceObject("default"))
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: Namespace
+metadata:
+  name: payments
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: payments
+data:
+  mode: new
+`, differ.Options{}, clusterOptions{})
+	if err != nil {
+		t.Fatalf("diffCluster() error = %v", err)
+	}
+	if !strings.Contains(out, `depends on Namespace "payments" in this batch`) {
+		t.Errorf("diffCluster() output missing pending note:\n%s", out)
+	}
+}
+
+func TestDiffClusterAutoFallback(t *testing.T) {
+	fake := cluster.NewFake("default", nil,
+		namespaceObject("default"),
+		configMap("default", "app", nil, map[string]interface{}{"mode": "old"}),
+	)
+	fake.DryRunError = apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "app", nil)
+
+	manifest := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  mode: new
+`
+	if _, err := runFakeClusterDiff(t, fake, manifest, differ.Options{}, clusterOptions{}); err == nil {
+		t.Error("diffCluster() with server strategy succeeded, want dry-run error")
+	}
+
+	out, err := runFakeClusterDiff(t, fake, manifest, differ.Options{}, clusterOptions{diffStrategy: diffStrategyAuto})
+	if err != nil {
+		t.Fatalf("diffCluster() error = %v", err)
+	}
+	if !strings.Contains(out, "# Note: server-side dry-run unavailable (Forbidden, 403), falling back to client-side diff") || !strings.Contains(out, "+  mode: new") {
+		t.Errorf("diffCluster() output missing fallback diff:\n%s", out)
+	}
+	if strings.Contains(out, "configmaps") {
+		t.Errorf("diffCluster() fallback note quotes the error message:\n%s", out)
+	}
+
+	// An invalid manifest or a failing webhook would fail the apply too, so
+	// it is reported rather than hidden behind a client-side diff.
+	fake.DryRunError = apierrors.NewInternalError(errors.New("webhook denied the request"))
+	if _, err := runFakeClusterDiff(t, fake, manifest, differ.Options{}, clusterOptions{diffStrategy: diffStrategyAuto}); err == nil || !strings.Contains(err.Error(), "webhook denied") {
+		t.Errorf("diffCluster() with a failing webhook error = %v, want the webhook error", err)
+	}
+}
+
+func TestDiffClusterSecureErrors(t *testing.T) {
+	fake := cluster.NewFake("default", nil, namespaceObject("default"))
+	fake.DryRunError = apierrors.NewBadRequest(`admission webhook denied the request: token "hunter2-value" is not allowed`)
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: Secret
+metadata:
+  name: app
+stringData:
+  token: hunter2-value
+`, differ.Options{SecureMode: true}, clusterOptions{})
+	if err == nil {
+		t.Fatal("diffCluster() succeeded, want the dry-run error")
+	}
+	if strings.Contains(out, "hunter2-value") || strings.Contains(err.Error(), "hunter2-value") {
+		t.Errorf("diffCluster() in secure mode quotes the server's message:\n%s\n%v", out, err)
+	}
+	if !strings.Contains(out, "request failed (BadRequest, 400)") {
+		t.Errorf("diffCluster() output missing the error status:\n%s", out)
+	}
+}
+
+func TestDiffClusterIntent(t *testing.T) {
+	live := configMap("default", "app", map[string]interface{}{"app": "web"}, map[string]interface{}{"mode": "fast", "added-by-hand": "x"})
+	live.SetResourceVersion("42")
+	fake := cluster.NewFake("default", nil, namespaceObject("default"), live)
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  mode: fast
+`, differ.Options{}, clusterOptions{mode: modeIntent})
+	if err != nil {
+		t.Fatalf("diffCluster() error = %v", err)
+	}
+	if !strings.Contains(out, "# No Changes") {
+		t.Errorf("diffCluster() in intent mode reported undeclared fields:\n%s", out)
+	}
+}
+
+func TestDiffClusterIntentOwnership(t *testing.T) {
+	live := configMap("default", "app", nil, map[string]interface{}{"mode": "fast", "replicas": "7"})
+	live.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			Manager:    cluster.DefaultFieldManager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:mode":{}}}`)},
+		},
+		{
+			Manager:    "autoscaler",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:replicas":{}}}`)},
+		},
+	})
+	fake := cluster.NewFake("default", nil, namespaceObject("default"), live)
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  mode: fast
+  replicas: "3"
+`, differ.Options{}, clusterOptions{mode: modeIntent, fieldManager: cluster.DefaultFieldManager})
+	if err != nil {
+		t.Fatalf("diffCluster() error = %v", err)
+	}
+	if !strings.Contains(out, "# No Changes") || !strings.Contains(out, "#   .data.replicas: autoscaler (Update)") {
+		t.Errorf("diffCluster() in intent mode compared a field owned by another manager:\n%s", out)
+	}
+}
+
+func TestDiffClusterPruneSelector(t *testing.T) {
+	fake := cluster.NewFake("default", nil,
+		namespaceObject("default"),
+		configMap("default", "kept", map[string]interface{}{"app": "web"}, map[string]interface{}{"k": "v"}),
+		configMap("default", "stale", map[string]interface{}{"app": "web"}, map[string]interface{}{"k": "v"}),
+		configMap("default", "other", map[string]interface{}{"app": "db"}, map[string]interface{}{"k": "v"}),
+	)
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+  labels:
+    app: web
+data:
+  k: v
+`, differ.Options{}, clusterOptions{pruneSelector: "app=web"})
+	if err != nil {
+		t.Fatalf("diffCluster() error = %v", err)
+	}
+	if !strings.Contains(out, "will be deleted) [ConfigMap default/stale]") {
+		t.Errorf("diffCluster() output missing prune of stale:\n%s", out)
+	}
+	if strings.Contains(out, "default/other") {
+		t.Errorf("diffCluster() pruned a resource outside the selector:\n%s", out)
+	}
+}
+
+func TestDiffClusterPruneClusterScopedApplySet(t *testing.T) {
+	// A cluster-scoped parent without additional-namespaces records no
+	// namespace, so members are searched in those of the manifests.
+	parent := namespaceObject("web-set")
+	parent.SetLabels(map[string]string{cluster.ApplySetParentIDLabel: "applyset-web"})
+	parent.SetAnnotations(map[string]string{cluster.ApplySetGKsAnnotation: "ConfigMap"})
+	member := map[string]interface{}{cluster.ApplySetPartOfLabel: "applyset-web"}
+	fake := cluster.NewFake("default", nil,
+		namespaceObject("default"),
+		namespaceObject("web"),
+		parent,
+		configMap("web", "kept", member, map[string]interface{}{"k": "v"}),
+		configMap("web", "stale", member, map[string]interface{}{"k": "v"}),
+	)
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+  namespace: web
+  labels:
+    applyset.kubernetes.io/part-of: applyset-web
+data:
+  k: v
+`, differ.Options{}, clusterOptions{applySet: "namespaces/web-set"})
+	if err != nil {
+		t.Fatalf("diffCluster() error = %v", err)
+	}
+	if !strings.Contains(out, "will be deleted) [ConfigMap web/stale]") {
+		t.Errorf("diffCluster() output missing prune of stale:\n%s", out)
+	}
+	if strings.Count(out, "will be deleted") != 1 {
+		t.Errorf("diffCluster() pruned more than the stale member:\n%s", out)
+	}
+}
+
+func TestDiffClusterNamespaceMismatch(t *testing.T) {
+	fake := cluster.NewFake("default", nil, namespaceObject("default"), namespaceObject("prod"))
+
+	out, err := runFakeClusterDiff(t, fake, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: staging
+data:
+  k: v
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+data:
+  k: v
+`, differ.Options{Namespace: "prod"}, clusterOptions{})
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 resources failed") {
+		t.Fatalf("diffCluster() error = %v, want 1 of 2 resources failed", err)
+	}
+	if !strings.Contains(out, "[ConfigMap prod/other]") {
+		t.Errorf("diffCluster() output missing the valid resource:\n%s", out)
+	}
+}
+
+func TestDiffClusterImmutable(t *testing.T) {
+	live := configMap("default", "app", nil, map[string]interface{}{"mode": "old"})
+	live.Object["immutable"] = true
+	manifest := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+immutable: true
+data:
+  mode: new
+`
+
+	rejected := cluster.NewFake("default", nil, namespaceObject("default"), live)
+	rejected.DryRunError = apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "app", field.ErrorList{
+		field.Forbidden(field.NewPath("data"), "field is immutable when `immutable` is set"),
+	})
+
+	tests := []struct {
+		name string
+		fake cluster.Backend
+		opts differ.Options
+		want []string
+	}{
+		{
+			name: "detected locally",
+			fake: cluster.NewFake("default", nil, namespaceObject("default"), live),
+			want: []string{"# WARNING: immutable fields change", "#   data: the ConfigMap is immutable"},
+		},
+		{
+			name: "rejected by the server",
+			fake: rejected,
+			want: []string{"the server rejects this apply", "#   data: field is immutable when `immutable` is set", "+  mode: new"},
+		},
+		{
+			name: "rejected in secure mode",
+			fake: rejected,
+			opts: differ.Options{SecureMode: true},
+			want: []string{"the server rejects this apply", "#   data: FieldValueForbidden"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runFakeClusterDiff(t, tt.fake, manifest, tt.opts, clusterOptions{})
+			var exitErr *exitCodeError
+			if !errors.As(err, &exitErr) || exitErr.code != exitImmutable {
+				t.Fatalf("diffCluster() error = %v, want exit code %d", err, exitImmutable)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("diffCluster() output missing %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
@@ -2,25 +2,50 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/1azunna/k8s-diff-tool/internal/cluster"
 	"github.com/1azunna/k8s-diff-tool/internal/differ"
 	"github.com/1azunna/k8s-diff-tool/internal/loader"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
-	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 type cliOptions struct {
 	dirDiff      bool
 	secureMode   bool
+	scanSecrets  bool
+	maskStrategy []string
+	maskReveal   int
 	clusterMode  bool
 	kubeContext  string
+	kubeconfig   string
+	server       string
+	token        string
+	as           string
+	asGroups     []string
+	insecure     bool
+	timeout      string
+	cacheDir     string
+	discoveryTTL time.Duration
+	refreshDisc  bool
+	concurrency  int
+	mode         string
+	diffStrategy string
+	fieldManager string
+	conflicts    bool
+	outputPatch  string
+	applySet     string
+	pruneSel     string
 	includeKinds []string
 	excludeKinds []string
+	namespace    string
+	names        []string
+	selector     string
+	where        string
 }
 
 // Entrypoint creates the root command and encapsulates its flag state.
@@ -41,17 +66,35 @@ It supports calculating diffs for individual files or entire directories.`,
 				pathB = args[1]
 			}
 
-			diffOpts := differ.Options{
-				SecureMode:   opts.secureMode,
-				IncludeKinds: opts.includeKinds,
-				ExcludeKinds: opts.excludeKinds,
+			diffOpts, err := opts.diffOptions()
+			if err != nil {
+				return err
+			}
+
+			var patchFormat differ.PatchFormat
+			if opts.outputPatch != "" {
+				if opts.clusterMode {
+					return fmt.Errorf("--output-patch is not supported in cluster mode")
+				}
+				if patchFormat, err = differ.ParsePatchFormat(opts.outputPatch); err != nil {
+					return err
+				}
 			}
 
 			if opts.clusterMode {
 				if len(args) != 1 {
 					return fmt.Errorf("cluster mode requires exactly 1 argument (local path)")
 				}
-				return runClusterDiff(pathA, diffOpts, opts.kubeContext)
+				return runClusterDiff(cmd.Context(), cmd.OutOrStdout(), pathA, diffOpts, clusterOptions{
+					config:        opts.clusterConfig(opts.kubeContext),
+					concurrency:   opts.concurrency,
+					mode:          opts.mode,
+					diffStrategy:  opts.diffStrategy,
+					fieldManager:  opts.fieldManager,
+					conflicts:     opts.conflicts,
+					applySet:      opts.applySet,
+					pruneSelector: opts.pruneSel,
+				})
 			}
 
 			if len(args) != 2 {
@@ -73,7 +116,10 @@ It supports calculating diffs for individual files or entire directories.`,
 					return fmt.Errorf("both arguments must be directories when -d is used")
 				}
 
-				return runDirDiff(pathA, pathB, diffOpts)
+				if patchFormat != "" {
+					return runPatchDiff(cmd.OutOrStdout(), pathA, pathB, patchFormat, diffOpts)
+				}
+				return runDirDiff(cmd.OutOrStdout(), pathA, pathB, diffOpts)
 			}
 
 			// Default file mode
@@ -87,21 +133,140 @@ It supports calculating diffs for individual files or entire directories.`,
 				return fmt.Errorf("%s is a directory; use -d to diff directories", pathB)
 			}
 
-			return runFileDiff(pathA, pathB, diffOpts)
+			if patchFormat != "" {
+				return runPatchDiff(cmd.OutOrStdout(), pathA, pathB, patchFormat, diffOpts)
+			}
+			return runFileDiff(cmd.OutOrStdout(), pathA, pathB, diffOpts)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&opts.dirDiff, "dir", "d", false, "Compare two directories")
-	cmd.Flags().BoolVarP(&opts.secureMode, "secure", "s", false, "Mask sensitive data in Secrets and ConfigMaps")
+	cmd.Flags().StringVar(&opts.outputPatch, "output-patch", "", "Print, per resource, the patch transforming path1 into path2 instead of a diff: jsonpatch, merge or strategic")
 	cmd.Flags().BoolVarP(&opts.clusterMode, "cluster-mode", "c", false, "Compare local files with live cluster resources")
 	cmd.Flags().StringVar(&opts.kubeContext, "kube-context", "", "Kubernetes context to use")
-	cmd.Flags().StringSliceVarP(&opts.includeKinds, "include", "i", nil, "Filter resources by Kind (case-insensitive, comma-separated)")
-	cmd.Flags().StringSliceVarP(&opts.excludeKinds, "exclude", "e", nil, "Exclude resources by Kind (case-insensitive, comma-separated)")
+	cmd.Flags().StringVar(&opts.mode, "mode", modePredicted, "What cluster mode compares: predicted (the live object vs the result of applying) or intent (the fields local manifests declare vs their live values, to detect drift)")
+	cmd.Flags().StringVar(&opts.diffStrategy, "diff-strategy", diffStrategyServer, "How the target state is computed in cluster mode: server (dry-run apply), client (local manifest) or auto (server, falling back to client)")
+	cmd.Flags().StringVar(&opts.fieldManager, "field-manager", cluster.DefaultFieldManager, "Field manager server-side dry-run applies are made as; use the one your real applies use (cluster mode only)")
+	cmd.Flags().BoolVar(&opts.conflicts, "conflicts", false, "Report fields an apply would take over from other field managers, and who owns the changed fields today (cluster mode only)")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 1, "Number of resources compared with the cluster in parallel (cluster mode only)")
+	cmd.Flags().StringVar(&opts.applySet, "applyset", "", "Preview pruning of members of this ApplySet parent ([RESOURCE][.GROUP]/NAME) missing locally (cluster mode only)")
+	cmd.Flags().StringVar(&opts.pruneSel, "prune-selector", "", "Preview pruning of live resources of the local kinds matching this label selector (cluster mode only)")
+
+	// Masking and filtering flags are shared with the subcommands.
+	flags := cmd.PersistentFlags()
+	flags.BoolVarP(&opts.secureMode, "secure", "s", false, "Mask sensitive data in Secrets and ConfigMaps")
+	flags.BoolVar(&opts.scanSecrets, "scan-secrets", true, "Detect and mask credential-like strings in all resources (secure mode only)")
+	flags.StringSliceVar(&opts.maskStrategy, "mask-strategy", nil, "Mask strategy, globally or per Kind as Kind=strategy (hash, redact, fingerprint, length, partial, changed)")
+	flags.IntVar(&opts.maskReveal, "mask-reveal", 4, "Number of characters revealed at each end by the partial mask strategy")
+	flags.StringSliceVarP(&opts.includeKinds, "include", "i", nil, "Filter resources by Kind, plural, short name, group/Kind or group/version/Kind (comma-separated)")
+	flags.StringSliceVarP(&opts.excludeKinds, "exclude", "e", nil, "Exclude resources by Kind, plural, short name, group/Kind or group/version/Kind (comma-separated)")
+	flags.StringVarP(&opts.namespace, "namespace", "n", "", "Only include resources in this namespace (resources without a namespace are kept). In cluster mode, the namespace resources are applied to, as with kubectl")
+	flags.StringSliceVar(&opts.names, "name", nil, "Filter resources by name glob patterns (comma-separated, e.g. 'api-*')")
+	flags.StringVarP(&opts.selector, "selector", "l", "", "Filter resources by label selector (e.g. 'app=payments,tier!=cache')")
+	flags.StringVar(&opts.where, "where", "", "Filter resources by a CEL expression over 'object', kept if it matches on either side (e.g. 'object.kind == \"Deployment\" && object.spec.replicas > 3', or '\"team\" in object.metadata.annotations' to test for a key)")
+
+	// Connection flags have kubectl's meaning, for every command that talks
+	// to a cluster.
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use for cluster requests")
+	flags.StringVar(&opts.server, "server", "", "The address and port of the Kubernetes API server")
+	flags.StringVar(&opts.token, "token", "", "Bearer token for authentication to the API server")
+	flags.StringVar(&opts.as, "as", "", "Username to impersonate for the operation")
+	flags.StringArrayVar(&opts.asGroups, "as-group", nil, "Group to impersonate for the operation (can be repeated)")
+	flags.BoolVar(&opts.insecure, "insecure-skip-tls-verify", false, "If true, the server's certificate will not be checked for validity")
+	flags.StringVar(&opts.timeout, "request-timeout", "0", "The length of time to wait before giving up on a single server request (e.g. 1s, 2m); 0 means no timeout")
+	flags.StringVar(&opts.cacheDir, "cache-dir", cluster.DefaultCacheDir(), "Directory API discovery data is cached in, shared with kubectl; empty to cache in memory only")
+	flags.DurationVar(&opts.discoveryTTL, "discovery-cache-ttl", cluster.DefaultDiscoveryTTL, "How long cached API discovery data is used before it is fetched again")
+	flags.BoolVar(&opts.refreshDisc, "refresh-discovery", false, "Invalidate the API discovery cache before use")
+	cmd.MarkFlagsMutuallyExclusive("applyset", "prune-selector")
+
+	cmd.AddCommand(newClusterCommand(opts), newSnapshotCommand(opts), newSimulateCommand(opts), newCheckCommand(opts), newMergeCommand(opts))
+	for _, sub := range cmd.Commands() {
+		if sub.Flags().Lookup("all-namespaces") != nil {
+			sub.MarkFlagsMutuallyExclusive("namespace", "all-namespaces")
+		}
+	}
 
 	return cmd
 }
 
-func runFileDiff(pathA, pathB string, opts differ.Options) error {
+// addAllNamespacesFlag adds -A to a subcommand that lists live resources.
+// Other commands take their resources from manifests, where -n filters and
+// all namespaces are already included without it.
+func addAllNamespacesFlag(cmd *cobra.Command, allNS *bool) {
+	cmd.Flags().BoolVarP(allNS, "all-namespaces", "A", false, "List resources in all namespaces instead of the kubeconfig namespace")
+}
+
+// diffOptions builds the differ options from the shared masking and
+// filtering flags.
+func (o *cliOptions) diffOptions() (differ.Options, error) {
+	diffOpts := differ.Options{
+		SecureMode:   o.secureMode,
+		ScanSecrets:  o.scanSecrets,
+		MaskReveal:   o.maskReveal,
+		IncludeKinds: o.includeKinds,
+		ExcludeKinds: o.excludeKinds,
+		Namespace:    o.namespace,
+		Names:        o.names,
+		Selector:     o.selector,
+		Where:        o.where,
+	}
+	if err := applyMaskStrategies(&diffOpts, o.maskStrategy); err != nil {
+		return differ.Options{}, err
+	}
+	return diffOpts, nil
+}
+
+// clusterConfig builds the cluster connection configuration from the
+// connection flags, for the given kubeconfig context.
+func (o *cliOptions) clusterConfig(kubeContext string) cluster.Config {
+	return cluster.Config{
+		Kubeconfig:            o.kubeconfig,
+		Context:               kubeContext,
+		Server:                o.server,
+		Token:                 o.token,
+		Impersonate:           o.as,
+		ImpersonateGroups:     o.asGroups,
+		InsecureSkipTLSVerify: o.insecure,
+		RequestTimeout:        o.timeout,
+		CacheDir:              o.cacheDir,
+		DiscoveryTTL:          o.discoveryTTL,
+		RefreshDiscovery:      o.refreshDisc,
+	}
+}
+
+// applyMaskStrategies parses --mask-strategy values into the diff options.
+// A bare strategy sets the global default; Kind=strategy overrides the
+// strategy of that Kind's masking rule.
+func applyMaskStrategies(opts *differ.Options, values []string) error {
+	for _, v := range values {
+		kind, name, perKind := strings.Cut(v, "=")
+		if !perKind {
+			name = kind
+		}
+		strategy, err := differ.ParseMaskStrategy(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		if !perKind {
+			opts.MaskStrategy = strategy
+			continue
+		}
+
+		if opts.MaskingRules == nil {
+			opts.MaskingRules = differ.DefaultMaskingRules()
+		}
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		rule, ok := opts.MaskingRules[kind]
+		if !ok {
+			return fmt.Errorf("no masking rule for kind %q", kind)
+		}
+		rule.Strategy = strategy
+		opts.MaskingRules[kind] = rule
+	}
+	return nil
+}
+
+func runFileDiff(out io.Writer, pathA, pathB string, opts differ.Options) error {
 	dataA, err := loader.LoadFile(pathA)
 	if err != nil {
 		return err
@@ -116,12 +281,20 @@ func runFileDiff(pathA, pathB string, opts differ.Options) error {
 	if err != nil {
 		return err
 	}
+	immutable, err := differ.ImmutableChanges(dataA, dataB, opts)
+	if err != nil {
+		return err
+	}
 
-	fmt.Println(output)
+	fmt.Fprintln(out, output)
+	writeImmutableWarning(out, immutable, true)
+	if len(immutable) > 0 {
+		return errImmutable(immutableResources(immutable))
+	}
 	return nil
 }
 
-func runDirDiff(dirA, dirB string, opts differ.Options) error {
+func runDirDiff(out io.Writer, dirA, dirB string, opts differ.Options) error {
 	filesA, err := loader.ListYAMLFiles(dirA)
 	if err != nil {
 		return err
@@ -150,6 +323,7 @@ func runDirDiff(dirA, dirB string, opts differ.Options) error {
 	}
 	sort.Strings(allFiles)
 
+	immutableCount := 0
 	for _, filename := range allFiles {
 		var dataA, dataB []byte
 
@@ -173,138 +347,22 @@ func runDirDiff(dirA, dirB string, opts differ.Options) error {
 		if err != nil {
 			return fmt.Errorf("error diffing %s: %w", filename, err)
 		}
-
-		// Requirement: Header should be the filename before diff is displayed
-		fmt.Printf("# Diff for %s:\n", filename)
-		fmt.Println(diff)
-		// Add a separator for readability between files
-		fmt.Println("# --------------------------------------------------")
-	}
-
-	return nil
-}
-
-func runClusterDiff(path string, opts differ.Options, kubeContext string) error {
-	isDir, err := loader.IsDir(path)
-	if err != nil {
-		return fmt.Errorf("invalid path %s: %w", path, err)
-	}
-
-	client, err := cluster.NewClient(kubeContext)
-	if err != nil {
-		return fmt.Errorf("failed to create cluster client: %w", err)
-	}
-
-	if isDir {
-		return runClusterDirDiff(client, path, opts)
-	}
-	return runClusterFileDiff(client, path, opts)
-}
-
-func runClusterFileDiff(client *cluster.Client, path string, opts differ.Options) error {
-	data, err := loader.LoadFile(path)
-	if err != nil {
-		return err
-	}
-	return diffLocalWithCluster(client, data, path, opts)
-}
-
-func runClusterDirDiff(client *cluster.Client, dir string, opts differ.Options) error {
-	files, err := loader.ListYAMLFiles(dir)
-	if err != nil {
-		return err
-	}
-	sort.Strings(files)
-
-	for _, filename := range files {
-		fullPath := filepath.Join(dir, filename)
-		data, err := loader.LoadFile(fullPath)
+		immutable, err := differ.ImmutableChanges(dataA, dataB, opts)
 		if err != nil {
-			return fmt.Errorf("error reading %s: %w", fullPath, err)
+			return fmt.Errorf("error diffing %s: %w", filename, err)
 		}
+		immutableCount += immutableResources(immutable)
 
-		// Header is handled in diffLocalWithCluster? No, let's keep it here for structure consistency
-		// BUT diffLocalWithCluster might output multiple resources if the file handles multiple docs.
-		// So we should let it handle printing.
-		if err := diffLocalWithCluster(client, data, filename, opts); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func diffLocalWithCluster(client *cluster.Client, localData []byte, filename string, opts differ.Options) error {
-	resources, err := cluster.ParseResources(localData)
-	if err != nil {
-		return fmt.Errorf("failed to parse %s: %w", filename, err)
+		// Requirement: Header should be the filename before diff is displayed
+		fmt.Fprintf(out, "# Diff for %s:\n", filename)
+		fmt.Fprintln(out, diff)
+		writeImmutableWarning(out, immutable, true)
+		// Add a separator for readability between files
+		fmt.Fprintln(out, "# --------------------------------------------------")
 	}
 
-	for _, localRes := range resources {
-		gvk := localRes.GroupVersionKind()
-		name := localRes.GetName()
-		namespace := localRes.GetNamespace()
-		if namespace == "" {
-			namespace = "default" // fallback or rely on client default. Client handles empty anyway?
-			// client.GetResource uses default if empty.
-			// But creating consistent identifier for output
-		}
-
-		// Check if resource exists mainly to distinguish between "create" and "update" logic,
-		// but SSA handles both. However, for diffing, we want to verify connectivity?
-		// Actually, standard kubectl diff simply does SSA dry-run.
-
-		// 1. Fetch live resource (current state)
-		liveRes, err := client.GetResource(gvk.GroupVersion().String(), gvk.Kind, name, namespace)
-		if err != nil && !errors.IsNotFound(err) {
-			return fmt.Errorf("failed to fetch resource %s/%s: %w", namespace, name, err)
-		}
-
-		// 2. Compute Dry-Run Apply (Predicted state)
-		//    If liveRes is missing, SSA Dry-Run will show the Creation result (defaults applied).
-		//    If liveRes exists, SSA Dry-Run will show the Merged result.
-		dryRunRes, err := client.ServerSideApplyDryRun(localRes)
-		if err != nil {
-			return fmt.Errorf("failed to server-side dry-run apply: %w", err)
-		}
-
-		// 3. Prepare bytes for diffing.
-		//    Option A: Diff Live vs DryRun.
-		//       - Default assumption for "what will change".
-		//       - If Live missing: Diff Empty vs DryRun (Creation).
-		//       - If Live exists: Diff Live vs DryRun (Update).
-
-		var liveBytes []byte
-		if liveRes != nil {
-			// Clean live resource too?
-			// The DryRun result comes from the API server and contains full metadata (creationTimestamp, uid, etc of the Live object).
-			// If we diff Live vs DryRun, those fields match perfectly.
-			// But managedFields might change.
-			// We should strip managedFields from BOTH to avoid noise, as SSA updates managedFields.
-			unstructured.RemoveNestedField(liveRes.Object, "metadata", "managedFields")
-			liveBytes, _ = yaml.Marshal(liveRes.Object)
-		}
-
-		// For the "Target" (Predicted), we use the DryRun result.
-		// We also strip managedFields because "kdiff" manager entry will be new.
-		unstructured.RemoveNestedField(dryRunRes.Object, "metadata", "managedFields")
-		predictedBytes, _ := yaml.Marshal(dryRunRes.Object)
-
-		// We use the Differ.
-		// Wait, if we use Diff(Live, Predicted), we check "What changes?".
-		// This is correct.
-		// But earlier we used Diff(Live, Local).
-		// Local was "Target".
-		// Now Predicted is "Target".
-		localBytes := predictedBytes
-
-		diff, err := differ.Diff(liveBytes, localBytes, opts)
-		if err != nil {
-			return err
-		}
-
-		fmt.Printf("# Diff for %s (Cluster vs Local) [%s %s/%s]:\n", filename, gvk.Kind, namespace, name)
-		fmt.Println(diff)
-		fmt.Println("# --------------------------------------------------")
+	if immutableCount > 0 {
+		return errImmutable(immutableCount)
 	}
 	return nil
 }
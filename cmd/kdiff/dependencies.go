@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// batchDependencies records the Namespaces and CustomResourceDefinitions
+// declared in the local batch. Resources that depend on them cannot be
+// dry-run applied until they exist, so they are reported as creations instead.
+type batchDependencies struct {
+	// namespaces are the names of Namespaces in the batch.
+	namespaces map[string]bool
+	// crds maps the GroupKinds defined by CRDs in the batch to the CRD name
+	// and whether the kind is namespaced.
+	crds map[schema.GroupKind]batchCRD
+}
+
+// batchCRD is a CustomResourceDefinition declared in the local batch.
+type batchCRD struct {
+	name       string
+	namespaced bool
+}
+
+var (
+	namespaceGK = schema.GroupKind{Kind: "Namespace"}
+	crdGK       = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+)
+
+// findBatchDependencies scans the local items for Namespaces and CRDs.
+func findBatchDependencies(items []clusterItem) *batchDependencies {
+	deps := &batchDependencies{
+		namespaces: make(map[string]bool),
+		crds:       make(map[schema.GroupKind]batchCRD),
+	}
+
+	for _, item := range items {
+		if item.local == nil || item.prune {
+			continue
+		}
+		switch item.local.GroupVersionKind().GroupKind() {
+		case namespaceGK:
+			deps.namespaces[item.local.GetName()] = true
+		case crdGK:
+			group, _, _ := unstructured.NestedString(item.local.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(item.local.Object, "spec", "names", "kind")
+			scope, _, _ := unstructured.NestedString(item.local.Object, "spec", "scope")
+			if kind != "" {
+				deps.crds[schema.GroupKind{Group: group, Kind: kind}] = batchCRD{
+					name:       item.local.GetName(),
+					namespaced: scope != "Cluster",
+				}
+			}
+		}
+	}
+	return deps
+}
+
+// resolveNamespaces sets the namespace of custom resources whose CRD is in the
+// batch. The cluster can't map them yet, so cluster.Client.ResolveNamespace
+// left them untouched; the CRD's scope is used instead.
+func (d *batchDependencies) resolveNamespaces(client cluster.Backend, items []clusterItem, override string) {
+	for i, item := range items {
+		if item.local == nil || item.err != nil || item.prune {
+			continue
+		}
+		crd, ok := d.crds[item.local.GroupVersionKind().GroupKind()]
+		if !ok {
+			continue
+		}
+
+		namespace := item.local.GetNamespace()
+		switch {
+		case !crd.namespaced:
+			namespace = ""
+		case namespace != "" && override != "" && namespace != override:
+			items[i].err = fmt.Errorf("%w: the namespace from the provided object %q does not match the namespace %q. You must pass '--namespace=%s' to perform this operation", cluster.ErrNamespaceMismatch, namespace, override, namespace)
+			continue
+		case namespace == "" && override != "":
+			namespace = override
+		case namespace == "":
+			namespace = client.DefaultNamespace()
+		}
+		item.local.SetNamespace(namespace)
+	}
+}
+
+// pendingReason explains why a resource can't be dry-run applied yet, or
+// returns "" if it doesn't depend on anything in the batch.
+// missingKind reports that the cluster doesn't know the resource's kind, and
+// missingNamespace that its namespace doesn't exist.
+func (d *batchDependencies) pendingReason(obj *unstructured.Unstructured, missingKind, missingNamespace bool) string {
+	if d == nil {
+		return ""
+	}
+	if missingKind {
+		if crd, ok := d.crds[obj.GroupVersionKind().GroupKind()]; ok {
+			return fmt.Sprintf("will be created; depends on CustomResourceDefinition %q in this batch", crd.name)
+		}
+	}
+	if missingNamespace && d.namespaces[obj.GetNamespace()] {
+		return fmt.Sprintf("will be created; depends on Namespace %q in this batch", obj.GetNamespace())
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+)
+
+// writeImmutableWarning prints the immutable field changes of a diff, if any.
+// Resources are named unless the diff covers a single resource.
+func writeImmutableWarning(out io.Writer, changes []differ.ImmutableChange, named bool) {
+	if len(changes) == 0 {
+		return
+	}
+	if named {
+		fmt.Fprintln(out, "# WARNING: immutable fields change; the apply will be rejected unless these resources are deleted and recreated:")
+	} else {
+		fmt.Fprintln(out, "# WARNING: immutable fields change; the apply will be rejected unless the resource is deleted and recreated:")
+	}
+	for _, c := range changes {
+		if named {
+			fmt.Fprintf(out, "#   [%s %s] %s: %s\n", c.Kind, qualifiedName(c.Namespace, c.Name), c.Field, c.Reason)
+		} else {
+			fmt.Fprintf(out, "#   %s: %s\n", c.Field, c.Reason)
+		}
+	}
+}
+
+// immutableResources counts the resources with immutable field changes.
+func immutableResources(changes []differ.ImmutableChange) int {
+	seen := make(map[string]bool)
+	for _, c := range changes {
+		seen[c.Kind+"/"+c.Namespace+"/"+c.Name] = true
+	}
+	return len(seen)
+}
+
+// errImmutable reports resources with immutable field changes, ending kdiff
+// with exitImmutable.
+func errImmutable(count int) error {
+	return &exitCodeError{
+		code: exitImmutable,
+		err:  fmt.Errorf("%d resources change immutable fields", count),
+	}
+}
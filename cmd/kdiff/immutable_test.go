@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileDiffImmutable(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	for dir, app := range map[string]string{dirA: "api", dirB: "web"} {
+		doc := "apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api, namespace: prod}\nspec: {selector: {matchLabels: {app: " + app + "}}}\n"
+		if err := os.WriteFile(filepath.Join(dir, "api.yaml"), []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "file mode", args: []string{filepath.Join(dirA, "api.yaml"), filepath.Join(dirB, "api.yaml")}},
+		{name: "directory mode", args: []string{"-d", dirA, dirB}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			cmd := Entrypoint()
+			cmd.SetArgs(tt.args)
+			cmd.SetOut(&out)
+			cmd.SetErr(new(strings.Builder))
+			err := cmd.Execute()
+
+			var exitErr *exitCodeError
+			if !errors.As(err, &exitErr) || exitErr.code != exitImmutable {
+				t.Fatalf("Execute() error = %v, want exit code %d", err, exitImmutable)
+			}
+			if want := "#   [Deployment prod/api] spec.selector: label selectors can't be changed"; !strings.Contains(out.String(), want) {
+				t.Errorf("Execute() output missing %q:\n%s", want, out.String())
+			}
+		})
+	}
+}
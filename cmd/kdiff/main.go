@@ -1,13 +1,55 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
+// Exit codes.
+const (
+	exitFailure = 1
+	// exitImmutable means the diff changes immutable fields, so applying it
+	// is rejected unless the resources are deleted and recreated.
+	exitImmutable = 3
+	// exitPolicy means policy rules found changes at or above the --fail-on
+	// severity.
+	exitPolicy = 4
+	// exitConflict means kdiff merge found changes made differently on both
+	// sides.
+	exitConflict = 5
+	// exitInterrupted follows the shell convention for SIGINT.
+	exitInterrupted = 130
+)
+
+// exitCodeError is an error that ends kdiff with a specific exit code.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+
+func (e *exitCodeError) Unwrap() error { return e.err }
+
 func main() {
-	if err := Entrypoint().Execute(); err != nil {
+	// Ctrl-C cancels in-flight API requests rather than killing the process
+	// mid-write.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := Entrypoint().ExecuteContext(ctx); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		if ctx.Err() != nil {
+			os.Exit(exitInterrupted)
+		}
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		os.Exit(exitFailure)
 	}
 }
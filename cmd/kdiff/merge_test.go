@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+)
+
+func TestRunMergeConflict(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 3)
+	for i, retries := range []string{"3", "5", "7"} {
+		paths[i] = filepath.Join(dir, []string{"base", "ours", "theirs"}[i]+".yaml")
+		doc := "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: cfg}\ndata: {retries: \"" + retries + "\"}\n"
+		if err := os.WriteFile(paths[i], []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	err := runMerge(&out, paths[0], paths[1], paths[2], differ.Options{})
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != exitConflict {
+		t.Fatalf("runMerge() error = %v, want exit code %d", err, exitConflict)
+	}
+	if !strings.Contains(out.String(), "1 conflicting") {
+		t.Errorf("runMerge() output missing the conflict count:\n%s", out.String())
+	}
+}
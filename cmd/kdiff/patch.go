@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+)
+
+// runPatchDiff prints the patches transforming the resources of pathA into
+// those of pathB. Directories are read whole, so resources moved between
+// files are still paired.
+func runPatchDiff(out io.Writer, pathA, pathB string, format differ.PatchFormat, opts differ.Options) error {
+	dataA, err := loadManifests(pathA)
+	if err != nil {
+		return err
+	}
+	dataB, err := loadManifests(pathB)
+	if err != nil {
+		return err
+	}
+
+	patches, err := differ.Patches(dataA, dataB, format, opts)
+	if err != nil {
+		return err
+	}
+	if len(patches) == 0 {
+		fmt.Fprintln(out, "# No Changes")
+		return nil
+	}
+
+	// Patches are separated as YAML documents, so the output can be saved as
+	// a multi-document patch file.
+	for i, p := range patches {
+		c := p.Change
+		if i > 0 {
+			fmt.Fprintln(out, "---")
+		}
+		fmt.Fprintf(out, "# Patch for [%s %s] (%s):\n", c.Kind, qualifiedName(c.Namespace, c.Name), p.Format)
+		switch c.Type {
+		case differ.ChangeAdded:
+			fmt.Fprintf(out, "# Note: only in %s; add it as a resource\n", pathB)
+		case differ.ChangeRemoved:
+			fmt.Fprintf(out, "# Note: only in %s; delete it\n", pathA)
+		default:
+			if p.Format != format {
+				fmt.Fprintf(out, "# Note: no built-in schema for %s, so lists are replaced whole\n", c.Kind)
+			}
+			if p.Masked {
+				fmt.Fprintln(out, "# WARNING: masked values: this patch sets masked placeholders, not the real values; don't apply it as is")
+			}
+			fmt.Fprint(out, string(p.Patch))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"github.com/1azunna/k8s-diff-tool/internal/loader"
+	"github.com/1azunna/k8s-diff-tool/internal/simulate"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// simulateOptions holds the settings of the simulate subcommand.
+type simulateOptions struct {
+	// openAPIFiles are OpenAPI documents describing kinds beyond the bundled
+	// built-in types, such as custom resources.
+	openAPIFiles []string
+	fieldManager string
+}
+
+// newSimulateCommand creates the subcommand predicting server-side applies
+// offline, from a snapshot of the live objects.
+func newSimulateCommand(opts *cliOptions) *cobra.Command {
+	sopts := &simulateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "simulate LIVE LOCAL",
+		Short: "Predict a server-side apply offline, from a snapshot of live resources",
+		Long: `Merge local manifests onto live objects saved in files or a directory (such as
+a 'kdiff snapshot'), the way a server-side apply would, and diff the live
+objects with the result. No cluster is contacted.
+
+Built-in kinds are merged with their bundled schemas, and other kinds with the
+schemas of --openapi documents (saved from the API server's /openapi/v2 or
+/openapi/v3 endpoints). Kinds without a schema are merged with lists replaced
+whole. Server defaulting, admission webhooks and validation are not simulated.
+
+Fields are only removed if the live objects include their managedFields and
+the --field-manager applied them before; snapshots strip managedFields, so
+save the live objects with 'kubectl get -o yaml --show-managed-fields' to
+predict removals.`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			diffOpts, err := opts.diffOptions()
+			if err != nil {
+				return err
+			}
+			return runSimulate(cmd.OutOrStdout(), args[0], args[1], diffOpts, *sopts)
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sopts.openAPIFiles, "openapi", nil, "OpenAPI v2 or v3 JSON document with schemas for kinds that aren't built in (can be repeated)")
+	cmd.Flags().StringVar(&sopts.fieldManager, "field-manager", cluster.DefaultFieldManager, "Field manager the simulated applies are made as")
+
+	return cmd
+}
+
+func runSimulate(out io.Writer, livePath, localPath string, opts differ.Options, sopts simulateOptions) error {
+	sim, err := simulate.NewSimulator(sopts.openAPIFiles...)
+	if err != nil {
+		return err
+	}
+	filter, err := differ.NewFilter(opts)
+	if err != nil {
+		return err
+	}
+
+	liveObjs, err := loadResources(livePath)
+	if err != nil {
+		return err
+	}
+	live := make(map[string]*unstructured.Unstructured, len(liveObjs))
+	for _, obj := range liveObjs {
+		live[simulateKey(obj, obj.GetNamespace())] = obj
+	}
+
+	localObjs, err := loadResources(localPath)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	total, immutable := 0, 0
+	for _, local := range localObjs {
+		matched, err := filter.Match(local.Object)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+		total++
+
+		liveRes := findLive(live, local, opts.Namespace)
+		if liveRes != nil {
+			local = local.DeepCopy()
+			local.SetNamespace(liveRes.GetNamespace())
+		}
+		output, changes, err := renderSimulated(sim, liveRes, local, opts, sopts)
+		if err != nil {
+			fmt.Fprintf(out, "# Error for [%s %s]: %v\n", local.GetKind(), qualifiedName(local.GetNamespace(), local.GetName()), err)
+			fmt.Fprintln(out, "# --------------------------------------------------")
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprint(out, output)
+		if len(changes) > 0 {
+			immutable++
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d resources failed: %w", len(errs), total, errors.Join(errs...))
+	}
+	if immutable > 0 {
+		return errImmutable(immutable)
+	}
+	return nil
+}
+
+// loadResources parses the resources of a file, or of every YAML file in a
+// directory in filename order.
+func loadResources(path string) ([]*unstructured.Unstructured, error) {
+	isDir, err := loader.IsDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	paths := []string{path}
+	if isDir {
+		files, err := loader.ListYAMLFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		paths = paths[:0]
+		for _, f := range files {
+			paths = append(paths, filepath.Join(path, f))
+		}
+	}
+
+	var objs []*unstructured.Unstructured
+	for _, p := range paths {
+		data, err := loader.LoadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", p, err)
+		}
+		resources, err := cluster.ParseResources(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", p, err)
+		}
+		objs = append(objs, resources...)
+	}
+	return objs, nil
+}
+
+// simulateKey identifies a resource by group, kind, namespace and name.
+func simulateKey(obj *unstructured.Unstructured, namespace string) string {
+	return obj.GroupVersionKind().GroupKind().String() + "/" + namespace + "/" + obj.GetName()
+}
+
+// findLive returns the live counterpart of a local resource, or nil. Without
+// a cluster to tell which kinds are namespaced, a local resource without a
+// namespace matches a live one in the --namespace (or "default"), then a
+// cluster-scoped one.
+func findLive(live map[string]*unstructured.Unstructured, local *unstructured.Unstructured, namespace string) *unstructured.Unstructured {
+	if ns := local.GetNamespace(); ns != "" {
+		return live[simulateKey(local, ns)]
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	if obj, ok := live[simulateKey(local, namespace)]; ok {
+		return obj
+	}
+	return live[simulateKey(local, "")]
+}
+
+// renderSimulated diffs a live object with the result of applying local to
+// it, and returns the immutable fields the apply changes.
+func renderSimulated(sim *simulate.Simulator, liveRes, localRes *unstructured.Unstructured, opts differ.Options, sopts simulateOptions) (string, []differ.ImmutableChange, error) {
+	result, err := sim.Apply(liveRes, localRes, sopts.fieldManager)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var liveBytes []byte
+	if liveRes != nil {
+		liveBytes, _ = yaml.Marshal(cluster.Normalize(liveRes).Object)
+	}
+	targetBytes, _ := yaml.Marshal(cluster.Normalize(result).Object)
+
+	diff, err := differ.Diff(liveBytes, targetBytes, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	immutable, err := differ.ImmutableChanges(liveBytes, targetBytes, differ.Options{})
+	if err != nil {
+		return "", nil, err
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Diff for [%s %s] (Live vs Simulated):\n", localRes.GetKind(), qualifiedName(localRes.GetNamespace(), localRes.GetName()))
+	if liveRes == nil {
+		fmt.Fprintln(&out, "# Note: not in the live objects; shown as a creation")
+	}
+	fmt.Fprintln(&out, diff)
+	writeImmutableWarning(&out, immutable, false)
+	fmt.Fprintln(&out, "# --------------------------------------------------")
+	return out.String(), immutable, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"github.com/1azunna/k8s-diff-tool/internal/loader"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// snapshotOptions holds the settings of the snapshot subcommand.
+type snapshotOptions struct {
+	config      cluster.Config
+	kubeContext string
+	outputDir   string
+	allNS       bool
+}
+
+// newSnapshotCommand creates the subcommand writing live resources to a
+// directory, for offline diffs in directory mode.
+func newSnapshotCommand(opts *cliOptions) *cobra.Command {
+	sopts := &snapshotOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "snapshot -i KINDS -o DIR",
+		Short: "Write live cluster resources to a directory",
+		Long: `Snapshot the live resources of the --include kinds, in the --namespace (or all
+namespaces with -A) and matching the --selector, to a directory with one YAML
+file per resource. Server-populated fields are removed and, with --secure,
+sensitive data is masked. Snapshots can be compared with 'kdiff -d'.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			diffOpts, err := opts.diffOptions()
+			if err != nil {
+				return err
+			}
+			sopts.config = opts.clusterConfig(sopts.kubeContext)
+			return runSnapshot(cmd.Context(), cmd.OutOrStdout(), diffOpts, *sopts)
+		},
+	}
+
+	cmd.Flags().StringVar(&sopts.kubeContext, "context", "", "Kubernetes context to snapshot")
+	cmd.Flags().StringVarP(&sopts.outputDir, "output", "o", "", "Directory the snapshot is written to (created if missing; must not contain YAML files)")
+	addAllNamespacesFlag(cmd, &sopts.allNS)
+	_ = cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+func runSnapshot(ctx context.Context, out io.Writer, opts differ.Options, sopts snapshotOptions) error {
+	client, err := cluster.NewClient(sopts.config)
+	if err != nil {
+		return fmt.Errorf("failed to create cluster client: %w", err)
+	}
+	return writeSnapshot(ctx, out, client, opts, sopts)
+}
+
+// writeSnapshot writes the selected live resources of client to the output
+// directory, one file per resource.
+func writeSnapshot(ctx context.Context, out io.Writer, client cluster.Backend, opts differ.Options, sopts snapshotOptions) error {
+	if len(opts.IncludeKinds) == 0 {
+		return fmt.Errorf("select the resource types to snapshot with --include")
+	}
+
+	// Files of resources deleted since an earlier snapshot would linger and
+	// show up as unchanged in later diffs.
+	if existing, err := loader.ListYAMLFiles(sopts.outputDir); err == nil && len(existing) > 0 {
+		return fmt.Errorf("output directory %s already contains YAML files; use a new directory per snapshot", sopts.outputDir)
+	}
+
+	opts.KindResolver = client.ResolveKind
+	filter, err := differ.NewFilter(opts)
+	if err != nil {
+		return err
+	}
+
+	groupKinds, err := resolveGroupKinds(client, opts.IncludeKinds)
+	if err != nil {
+		return err
+	}
+	objs, err := client.ListBySelector(ctx, groupKinds, listNamespaces(client, opts.Namespace, sopts.allNS), opts.Selector)
+	if err != nil {
+		return err
+	}
+
+	// Unmasked snapshots may contain Secrets, so they are private by default.
+	if err := os.MkdirAll(sopts.outputDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", sopts.outputDir, err)
+	}
+
+	written := 0
+	for _, obj := range objs {
+		matched, err := filter.Match(obj.Object)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		data, err := yaml.Marshal(cluster.Normalize(obj).Object)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s %s: %w", obj.GetKind(), qualifiedName(obj.GetNamespace(), obj.GetName()), err)
+		}
+		if opts.SecureMode {
+			data, err = differ.Mask(data, opts)
+			if err != nil {
+				return fmt.Errorf("failed to mask %s %s: %w", obj.GetKind(), qualifiedName(obj.GetNamespace(), obj.GetName()), err)
+			}
+		}
+
+		path := filepath.Join(sopts.outputDir, snapshotFilename(obj))
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written++
+	}
+
+	fmt.Fprintf(out, "# Wrote %d resources to %s\n", written, sopts.outputDir)
+	return nil
+}
+
+// snapshotFilename names a resource's snapshot file as
+// kind[.group]_namespace_name.yaml, or kind[.group]_name.yaml for
+// cluster-scoped resources. Kinds, groups and names never contain "_", so
+// the name is unique and stable across snapshots.
+func snapshotFilename(obj *unstructured.Unstructured) string {
+	gk := obj.GroupVersionKind().GroupKind()
+	parts := []string{strings.ToLower(gk.Kind)}
+	if gk.Group != "" {
+		parts[0] += "." + gk.Group
+	}
+	if ns := obj.GetNamespace(); ns != "" {
+		parts = append(parts, ns)
+	}
+	parts = append(parts, obj.GetName())
+	return strings.Join(parts, "_") + ".yaml"
+}
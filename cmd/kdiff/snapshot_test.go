@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+)
+
+func TestSnapshotFilename(t *testing.T) {
+	tests := []struct {
+		obj  map[string]interface{}
+		want string
+	}{
+		{
+			obj:  map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "app", "namespace": "prod"}},
+			want: "configmap_prod_app.yaml",
+		},
+		{
+			obj:  map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": "api", "namespace": "prod"}},
+			want: "deployment.apps_prod_api.yaml",
+		},
+		{
+			obj:  map[string]interface{}{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": map[string]interface{}{"name": "admin"}},
+			want: "clusterrole.rbac.authorization.k8s.io_admin.yaml",
+		},
+	}
+	for _, tt := range tests {
+		if got := snapshotFilename(object(tt.obj)); got != tt.want {
+			t.Errorf("snapshotFilename() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	secret := object(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "creds", "namespace": "default", "resourceVersion": "42"},
+		"data":       map[string]interface{}{"password": "c3VwZXJzZWNyZXQ="},
+	})
+	fake := cluster.NewFake("default", nil,
+		secret,
+		configMap("default", "app", nil, map[string]interface{}{"mode": "fast"}),
+	)
+	dir := filepath.Join(t.TempDir(), "snapshot")
+
+	var out strings.Builder
+	opts := differ.Options{IncludeKinds: []string{"secrets", "configmaps"}, SecureMode: true}
+	if err := writeSnapshot(context.Background(), &out, fake, opts, snapshotOptions{outputDir: dir}); err != nil {
+		t.Fatalf("writeSnapshot() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "# Wrote 2 resources") {
+		t.Errorf("writeSnapshot() output = %q, want 2 resources written", out.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "secret_default_creds.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "c3VwZXJzZWNyZXQ=") || !strings.Contains(string(data), "password:") {
+		t.Errorf("snapshot of the Secret isn't masked:\n%s", data)
+	}
+	if strings.Contains(string(data), "resourceVersion") {
+		t.Errorf("snapshot of the Secret keeps server-populated fields:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "configmap_default_app.yaml")); err != nil {
+		t.Errorf("snapshot of the ConfigMap missing: %v", err)
+	}
+
+	// Files of resources deleted since would linger in a reused directory.
+	err = writeSnapshot(context.Background(), &out, fake, opts, snapshotOptions{outputDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "already contains YAML files") {
+		t.Errorf("writeSnapshot() to a non-empty directory error = %v, want it refused", err)
+	}
+}
@@ -0,0 +1,185 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ApplySet labels and annotations, as defined by KEP-3659.
+const (
+	// ApplySetParentIDLabel is set on the parent object and holds the ApplySet ID.
+	ApplySetParentIDLabel = "applyset.kubernetes.io/id"
+	// ApplySetPartOfLabel is set on every member and holds the ApplySet ID.
+	ApplySetPartOfLabel = "applyset.kubernetes.io/part-of"
+	// ApplySetGKsAnnotation lists the member GroupKinds as Kind.group.
+	ApplySetGKsAnnotation = "applyset.kubernetes.io/contains-group-kinds"
+	// ApplySetGRsAnnotation is the older form of ApplySetGKsAnnotation, listing
+	// resource.group instead.
+	ApplySetGRsAnnotation = "applyset.kubernetes.io/contains-group-resources"
+	// ApplySetAdditionalNamespacesAnnotation lists namespaces with members other
+	// than the parent's own.
+	ApplySetAdditionalNamespacesAnnotation = "applyset.kubernetes.io/additional-namespaces"
+)
+
+// ApplySet describes the members of an ApplySet as recorded on its parent.
+type ApplySet struct {
+	// ID is the value members carry in their part-of label.
+	ID string
+	// GroupKinds are the kinds of the members.
+	GroupKinds []schema.GroupKind
+	// Namespaces are the namespaces members live in.
+	Namespaces []string
+}
+
+// Selector returns the label selector matching the ApplySet's members.
+func (s *ApplySet) Selector() string {
+	return ApplySetPartOfLabel + "=" + s.ID
+}
+
+// GetApplySet fetches an ApplySet parent and reads its membership metadata.
+// ref has kubectl's --applyset form, [RESOURCE][.GROUP]/NAME, where RESOURCE
+// defaults to secrets. namespace is used for namespaced parents.
+func (c *Client) GetApplySet(ctx context.Context, ref, namespace string) (*ApplySet, error) {
+	resource, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		resource, name = "secrets", ref
+	}
+	if resource == "" || name == "" {
+		return nil, fmt.Errorf("invalid applyset reference %q: expected [RESOURCE][.GROUP]/NAME", ref)
+	}
+
+	gr := schema.ParseGroupResource(resource)
+	gvk, err := c.mapper.KindFor(gr.WithVersion(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve applyset parent type %q: %w", resource, err)
+	}
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find GVR for %s: %w", gvk, err)
+	}
+
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	var parent *unstructured.Unstructured
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		namespace = ""
+		parent, err = c.dynamicClient.Resource(mapping.Resource).Get(ctx, name, metav1.GetOptions{})
+	} else {
+		parent, err = c.dynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch applyset parent %s: %w", ref, err)
+	}
+
+	id := parent.GetLabels()[ApplySetParentIDLabel]
+	if id == "" {
+		return nil, fmt.Errorf("%s is not an applyset parent: missing label %s", ref, ApplySetParentIDLabel)
+	}
+	set := &ApplySet{ID: id}
+
+	annotations := parent.GetAnnotations()
+	if gks, ok := annotations[ApplySetGKsAnnotation]; ok {
+		for _, entry := range splitList(gks) {
+			set.GroupKinds = append(set.GroupKinds, schema.ParseGroupKind(entry))
+		}
+	} else {
+		for _, entry := range splitList(annotations[ApplySetGRsAnnotation]) {
+			gvk, err := c.mapper.KindFor(schema.ParseGroupResource(entry).WithVersion(""))
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve applyset member type %q: %w", entry, err)
+			}
+			set.GroupKinds = append(set.GroupKinds, gvk.GroupKind())
+		}
+	}
+
+	if namespace != "" {
+		set.Namespaces = append(set.Namespaces, namespace)
+	}
+	set.Namespaces = append(set.Namespaces, splitList(annotations[ApplySetAdditionalNamespacesAnnotation])...)
+
+	return set, nil
+}
+
+// ListBySelector lists live objects of the given kinds matching a label
+// selector. Namespaced kinds are listed in each of the namespaces;
+// cluster-scoped kinds are listed once. Results are sorted by kind, namespace
+// and name.
+func (c *Client) ListBySelector(ctx context.Context, groupKinds []schema.GroupKind, namespaces []string, selector string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	opts := metav1.ListOptions{LabelSelector: selector}
+
+	for _, gk := range groupKinds {
+		mapping, err := c.mapper.RESTMapping(gk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find GVR for %s: %w", gk, err)
+		}
+
+		var lists []*unstructured.UnstructuredList
+		if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			list, err := c.dynamicClient.Resource(mapping.Resource).List(ctx, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %w", mapping.Resource.Resource, err)
+			}
+			lists = append(lists, list)
+		} else {
+			for _, ns := range namespaces {
+				list, err := c.dynamicClient.Resource(mapping.Resource).Namespace(ns).List(ctx, opts)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list %s in %s: %w", mapping.Resource.Resource, ns, err)
+				}
+				lists = append(lists, list)
+			}
+		}
+
+		for _, list := range lists {
+			for i := range list.Items {
+				objs = append(objs, &list.Items[i])
+			}
+		}
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		a, b := objs[i], objs[j]
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		return a.GetName() < b.GetName()
+	})
+	return objs, nil
+}
+
+// IsNamespaced reports whether resources of the given kind are namespaced.
+func (c *Client) IsNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, fmt.Errorf("failed to find GVR for %s: %w", gvk, err)
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// DefaultNamespace returns the namespace from the kubeconfig context.
+func (c *Client) DefaultNamespace() string {
+	return c.namespace
+}
+
+// splitList splits a comma-separated annotation value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Backend is the cluster access kdiff needs: reading and listing objects,
+// dry-run applies, and type mapping. Client implements it against a live
+// cluster and Fake in memory.
+type Backend interface {
+	// GetResource fetches an object. If namespace is empty, the default
+	// namespace is used for namespaced kinds.
+	GetResource(ctx context.Context, apiVersion, kind, name, namespace string) (*unstructured.Unstructured, error)
+	// ListBySelector lists objects of the given kinds matching a label
+	// selector, sorted by kind, namespace and name.
+	ListBySelector(ctx context.Context, groupKinds []schema.GroupKind, namespaces []string, selector string) ([]*unstructured.Unstructured, error)
+	// ServerSideApplyDryRun predicts the result of a server-side apply.
+	ServerSideApplyDryRun(ctx context.Context, local *unstructured.Unstructured, fieldManager string, force bool) (*unstructured.Unstructured, error)
+	// GetApplySet reads the membership metadata of an ApplySet parent.
+	GetApplySet(ctx context.Context, ref, namespace string) (*ApplySet, error)
+	// ResolveKind resolves a resource name to a GroupKind.
+	ResolveKind(resource schema.GroupResource) (schema.GroupKind, bool)
+	// ResolveNamespace returns the namespace an object is applied to.
+	ResolveNamespace(obj *unstructured.Unstructured, override string) (string, error)
+	// IsNamespaced reports whether objects of a kind are namespaced.
+	IsNamespaced(gvk schema.GroupVersionKind) (bool, error)
+	// DefaultNamespace returns the namespace used when none is given.
+	DefaultNamespace() string
+}
+
+var (
+	_ Backend = (*Client)(nil)
+	_ Backend = (*Fake)(nil)
+)
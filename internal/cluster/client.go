@@ -3,8 +3,10 @@ package cluster
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,13 +15,16 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/yaml"
-	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// ErrNamespaceMismatch is returned by ResolveNamespace when a resource's
+// explicit namespace conflicts with the requested namespace.
+var ErrNamespaceMismatch = errors.New("namespace mismatch")
+
 // Client wraps the dynamic client and REST mapper for interacting with the cluster.
 type Client struct {
 	dynamicClient dynamic.Interface
@@ -27,23 +32,44 @@ type Client struct {
 	namespace     string // Default namespace from kubeconfig
 }
 
-// NewClient creates a new Client using the default kubeconfig loading rules.
-func NewClient(kubeContext string) (*Client, error) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-	if kubeContext != "" {
-		configOverrides.CurrentContext = kubeContext
-	}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
-
-	config, err := kubeConfig.ClientConfig()
-	if err != nil {
-		return nil, fmt.Errorf("error building kubeconfig: %w", err)
-	}
+// Config selects the cluster and identity a Client connects as. Fields have
+// the meaning of the kubectl flags of the same name; zero values defer to the
+// kubeconfig.
+type Config struct {
+	// Kubeconfig is the kubeconfig file (--kubeconfig). If empty, the default
+	// loading rules apply ($KUBECONFIG, then ~/.kube/config).
+	Kubeconfig string
+	// Context is the kubeconfig context (--context).
+	Context string
+	// Server is the API server address (--server).
+	Server string
+	// Token is the bearer token (--token).
+	Token string
+	// Impersonate is the user to act as (--as).
+	Impersonate string
+	// ImpersonateGroups are the groups to act as (--as-group).
+	ImpersonateGroups []string
+	// InsecureSkipTLSVerify disables server certificate checks
+	// (--insecure-skip-tls-verify).
+	InsecureSkipTLSVerify bool
+	// RequestTimeout bounds each API request (--request-timeout), e.g. "30s";
+	// a bare integer is seconds and "0" means no timeout.
+	RequestTimeout string
+	// CacheDir is the directory discovery data is cached in (--cache-dir),
+	// usually DefaultCacheDir. If empty, it is cached in memory only.
+	CacheDir string
+	// DiscoveryTTL is how long cached discovery data is used. Defaults to
+	// DefaultDiscoveryTTL.
+	DiscoveryTTL time.Duration
+	// RefreshDiscovery invalidates the discovery cache before use.
+	RefreshDiscovery bool
+}
 
-	namespace, _, err := kubeConfig.Namespace()
+// NewClient creates a new Client from the kubeconfig loading rules and cfg.
+func NewClient(cfg Config) (*Client, error) {
+	config, namespace, err := restConfig(cfg)
 	if err != nil {
-		namespace = "default"
+		return nil, err
 	}
 
 	dynClient, err := dynamic.NewForConfig(config)
@@ -51,11 +77,12 @@ func NewClient(kubeContext string) (*Client, error) {
 		return nil, fmt.Errorf("error creating dynamic client: %w", err)
 	}
 
-	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	cachedDiscovery, err := newCachedDiscovery(config, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("error creating discovery client: %w", err)
 	}
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
+	// The shortcut expander lets the mapper resolve short names such as "deploy".
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscovery), cachedDiscovery, nil)
 
 	return &Client{
 		dynamicClient: dynClient,
@@ -64,9 +91,45 @@ func NewClient(kubeContext string) (*Client, error) {
 	}, nil
 }
 
+// restConfig builds the REST config and default namespace from the kubeconfig
+// loading rules, overridden by cfg.
+func restConfig(cfg Config) (*rest.Config, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = cfg.Kubeconfig
+	configOverrides := &clientcmd.ConfigOverrides{
+		CurrentContext: cfg.Context,
+		Timeout:        cfg.RequestTimeout,
+	}
+	configOverrides.ClusterInfo.Server = cfg.Server
+	configOverrides.ClusterInfo.InsecureSkipTLSVerify = cfg.InsecureSkipTLSVerify
+	configOverrides.AuthInfo.Token = cfg.Token
+	configOverrides.AuthInfo.Impersonate = cfg.Impersonate
+	configOverrides.AuthInfo.ImpersonateGroups = cfg.ImpersonateGroups
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+
+	config, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("error building kubeconfig: %w", err)
+	}
+
+	// The client-go defaults (5 QPS, burst 10) would throttle concurrent diffs.
+	if config.QPS == 0 {
+		config.QPS = 50
+	}
+	if config.Burst == 0 {
+		config.Burst = 100
+	}
+
+	namespace, _, err := kubeConfig.Namespace()
+	if err != nil {
+		namespace = "default"
+	}
+	return config, namespace, nil
+}
+
 // GetResource fetches a resource from the cluster given its GVK, name, and namespace.
 // If namespace is empty, it uses the client's default namespace (from context).
-func (c *Client) GetResource(apiVersion, kind, name, namespace string) (*unstructured.Unstructured, error) {
+func (c *Client) GetResource(ctx context.Context, apiVersion, kind, name, namespace string) (*unstructured.Unstructured, error) {
 	gv, err := schema.ParseGroupVersion(apiVersion)
 	if err != nil {
 		return nil, fmt.Errorf("invalid apiVersion %s: %w", apiVersion, err)
@@ -85,9 +148,9 @@ func (c *Client) GetResource(apiVersion, kind, name, namespace string) (*unstruc
 
 	var resource *unstructured.Unstructured
 	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
-		resource, err = c.dynamicClient.Resource(mapping.Resource).Get(context.TODO(), name, metav1.GetOptions{})
+		resource, err = c.dynamicClient.Resource(mapping.Resource).Get(ctx, name, metav1.GetOptions{})
 	} else {
-		resource, err = c.dynamicClient.Resource(mapping.Resource).Namespace(targetNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		resource, err = c.dynamicClient.Resource(mapping.Resource).Namespace(targetNamespace).Get(ctx, name, metav1.GetOptions{})
 	}
 
 	if err != nil {
@@ -97,6 +160,44 @@ func (c *Client) GetResource(apiVersion, kind, name, namespace string) (*unstruc
 	return resource, nil
 }
 
+// ResolveKind resolves a resource name (plural, singular or short name),
+// optionally qualified by group, to a GroupKind using discovery data.
+func (c *Client) ResolveKind(resource schema.GroupResource) (schema.GroupKind, bool) {
+	gvk, err := c.mapper.KindFor(resource.WithVersion(""))
+	if err != nil {
+		return schema.GroupKind{}, false
+	}
+	return gvk.GroupKind(), true
+}
+
+// ResolveNamespace returns the namespace a resource is applied to, using the
+// REST mapping to tell namespaced from cluster-scoped kinds. It follows kubectl
+// semantics: cluster-scoped resources have no namespace; namespaced resources
+// use their own namespace, else override, else the kubeconfig namespace. A
+// resource whose explicit namespace conflicts with override is an error.
+func (c *Client) ResolveNamespace(obj *unstructured.Unstructured, override string) (string, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", fmt.Errorf("failed to find GVR for %s: %w", gvk, err)
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return "", nil
+	}
+
+	namespace := obj.GetNamespace()
+	switch {
+	case namespace != "" && override != "" && namespace != override:
+		return "", fmt.Errorf("%w: the namespace from the provided object %q does not match the namespace %q. You must pass '--namespace=%s' to perform this operation", ErrNamespaceMismatch, namespace, override, namespace)
+	case namespace != "":
+		return namespace, nil
+	case override != "":
+		return override, nil
+	default:
+		return c.namespace, nil
+	}
+}
+
 // ParseResources parses YAML bytes into a slice of Unstructured objects.
 func ParseResources(data []byte) ([]*unstructured.Unstructured, error) {
 	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
@@ -121,7 +222,11 @@ func ParseResources(data []byte) ([]*unstructured.Unstructured, error) {
 }
 
 // ServerSideApplyDryRun performs a server-side apply in dry-run mode to calculate the "future state" of the resource.
-func (c *Client) ServerSideApplyDryRun(local *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+// The apply is made as fieldManager (DefaultFieldManager if empty). With force,
+// fields owned by other managers are taken over, simulating "if I applied
+// this, what happens?"; without it, such fields fail the apply with a
+// conflict (see ApplyConflicts).
+func (c *Client) ServerSideApplyDryRun(ctx context.Context, local *unstructured.Unstructured, fieldManager string, force bool) (*unstructured.Unstructured, error) {
 	gvk := local.GroupVersionKind()
 	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
 	if err != nil {
@@ -148,16 +253,16 @@ func (c *Client) ServerSideApplyDryRun(local *unstructured.Unstructured) (*unstr
 	}
 
 	// Perform the Patch with DryRun
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
 	patchOptions := metav1.PatchOptions{
-		FieldManager: "kdiff",
+		FieldManager: fieldManager,
 		DryRun:       []string{metav1.DryRunAll},
-		Force:        nil, // Set true if needed, but for diffing we usually want to see conflicts? No, we want to see result.
+		Force:        &force,
 	}
-	// Force ownership to simulate "If I applied this, what happens?"
-	force := true
-	patchOptions.Force = &force
 
-	applied, err := drClient.Patch(context.TODO(), name, types.ApplyPatchType, data, patchOptions)
+	applied, err := drClient.Patch(ctx, name, types.ApplyPatchType, data, patchOptions)
 	if err != nil {
 		return nil, fmt.Errorf("dry-run apply failed: %w", err)
 	}
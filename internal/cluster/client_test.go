@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+users:
+- name: deployer
+  user:
+    token: kubeconfig-token
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: deployer
+    namespace: team
+current-context: staging
+`
+
+func TestRestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, namespace, err := restConfig(Config{Kubeconfig: path})
+	if err != nil {
+		t.Fatalf("restConfig() error = %v", err)
+	}
+	if config.Host != "https://staging.example.com" || config.BearerToken != "kubeconfig-token" || namespace != "team" {
+		t.Errorf("restConfig() = %s, %s, %s, want the kubeconfig's server, token and namespace", config.Host, config.BearerToken, namespace)
+	}
+	if config.Timeout != 0 || config.Impersonate.UserName != "" {
+		t.Errorf("restConfig() timeout = %v, impersonate = %q, want none", config.Timeout, config.Impersonate.UserName)
+	}
+
+	config, _, err = restConfig(Config{
+		Kubeconfig:        path,
+		Server:            "https://prod.example.com",
+		Token:             "flag-token",
+		Impersonate:       "jane",
+		ImpersonateGroups: []string{"auditors"},
+		RequestTimeout:    "30s",
+	})
+	if err != nil {
+		t.Fatalf("restConfig() error = %v", err)
+	}
+	if config.Host != "https://prod.example.com" {
+		t.Errorf("restConfig() host = %s, want the --server override", config.Host)
+	}
+	if config.BearerToken != "flag-token" {
+		t.Errorf("restConfig() token = %s, want the --token override", config.BearerToken)
+	}
+	if config.Impersonate.UserName != "jane" || !reflect.DeepEqual(config.Impersonate.Groups, []string{"auditors"}) {
+		t.Errorf("restConfig() impersonate = %+v, want jane in auditors", config.Impersonate)
+	}
+	if config.Timeout != 30*time.Second {
+		t.Errorf("restConfig() timeout = %v, want 30s", config.Timeout)
+	}
+
+	if _, _, err := restConfig(Config{Kubeconfig: path, RequestTimeout: "soon"}); err == nil {
+		t.Error("restConfig() with an invalid --request-timeout succeeded, want an error")
+	}
+}
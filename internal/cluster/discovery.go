@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/homedir"
+)
+
+// DefaultDiscoveryTTL is how long cached discovery data is used before it is
+// fetched again, as in kubectl.
+const DefaultDiscoveryTTL = 6 * time.Hour
+
+// illegalFileCharacters matches characters not allowed in cache directory
+// names. It is the pattern kubectl uses, so the cache is shared with it.
+var illegalFileCharacters = regexp.MustCompile(`[^(\w/.)]`)
+
+// DefaultCacheDir returns kubectl's cache directory: $KUBECACHEDIR, else
+// ~/.kube/cache. It returns "" if neither is available.
+func DefaultCacheDir() string {
+	if dir := os.Getenv("KUBECACHEDIR"); dir != "" {
+		return dir
+	}
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".kube", "cache")
+	}
+	return ""
+}
+
+// newCachedDiscovery returns a discovery client whose results are cached in
+// cfg.CacheDir for cfg.DiscoveryTTL, keyed by server, or only in memory if
+// cfg.CacheDir is empty. With cfg.RefreshDiscovery, the cache is invalidated
+// first.
+//
+// Either way, the REST mapper built on it refetches discovery data once if a
+// kind is not found in the cached data, so new CRDs are picked up.
+func newCachedDiscovery(config *rest.Config, cfg Config) (discovery.CachedDiscoveryInterface, error) {
+	var cached discovery.CachedDiscoveryInterface
+	if cfg.CacheDir == "" {
+		dc, err := discovery.NewDiscoveryClientForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		cached = memory.NewMemCacheClient(dc)
+	} else {
+		ttl := cfg.DiscoveryTTL
+		if ttl <= 0 {
+			ttl = DefaultDiscoveryTTL
+		}
+		dc, err := disk.NewCachedDiscoveryClientForConfig(config,
+			discoveryCacheDir(filepath.Join(cfg.CacheDir, "discovery"), config.Host),
+			filepath.Join(cfg.CacheDir, "http"), ttl)
+		if err != nil {
+			return nil, err
+		}
+		cached = dc
+	}
+
+	if cfg.RefreshDiscovery {
+		cached.Invalidate()
+	}
+	return cached, nil
+}
+
+// discoveryCacheDir returns the cache directory for a server, as kubectl
+// computes it.
+func discoveryCacheDir(parent, host string) string {
+	schemeless := strings.Replace(strings.Replace(host, "https://", "", 1), "http://", "", 1)
+	return filepath.Join(parent, illegalFileCharacters.ReplaceAllString(schemeless, "_"))
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestDiscoveryCacheDir(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "https://10.0.0.1:6443", want: "10.0.0.1_6443"},
+		{host: "http://localhost:8080", want: "localhost_8080"},
+		{host: "https://example.com/k8s/clusters/c-1", want: "example.com/k8s/clusters/c_1"},
+	}
+	for _, tt := range tests {
+		if got := discoveryCacheDir("cache", tt.host); got != filepath.Join("cache", tt.want) {
+			t.Errorf("discoveryCacheDir(%q) = %q, want %q", tt.host, got, filepath.Join("cache", tt.want))
+		}
+	}
+}
+
+// discoveryServer serves minimal discovery documents and counts requests for
+// the core API versions.
+func discoveryServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			fetches.Add(1)
+			w.Write([]byte(`{"kind":"APIVersions","versions":["v1"]}`))
+		case "/apis":
+			w.Write([]byte(`{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func TestCachedDiscovery(t *testing.T) {
+	server, fetches := discoveryServer(t)
+	cacheDir := t.TempDir()
+
+	// Each call stands for a new kdiff run sharing the disk cache.
+	fetch := func(cfg Config) {
+		t.Helper()
+		cfg.CacheDir = cacheDir
+		dc, err := newCachedDiscovery(&rest.Config{Host: server.URL}, cfg)
+		if err != nil {
+			t.Fatalf("newCachedDiscovery() error = %v", err)
+		}
+		if _, err := dc.ServerGroups(); err != nil {
+			t.Fatalf("ServerGroups() error = %v", err)
+		}
+	}
+
+	fetch(Config{DiscoveryTTL: time.Hour})
+	fetch(Config{DiscoveryTTL: time.Hour})
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("discovery fetched %d times within the TTL, want 1", got)
+	}
+
+	fetch(Config{DiscoveryTTL: time.Hour, RefreshDiscovery: true})
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("discovery fetched %d times after --refresh-discovery, want 2", got)
+	}
+
+	// Age the cache past the TTL.
+	old := time.Now().Add(-2 * time.Hour)
+	err := filepath.WalkDir(filepath.Join(cacheDir, "discovery"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch(Config{DiscoveryTTL: time.Hour})
+	if got := fetches.Load(); got != 3 {
+		t.Errorf("discovery fetched %d times after the TTL expired, want 3", got)
+	}
+}
@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// FakeKind is a kind served by a Fake.
+type FakeKind struct {
+	GVK        schema.GroupVersionKind
+	Namespaced bool
+}
+
+// DefaultFakeKinds are the kinds every Fake serves.
+var DefaultFakeKinds = []FakeKind{
+	{GVK: schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}},
+	{GVK: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Version: "v1", Kind: "Service"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, Namespaced: true},
+	{GVK: schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}},
+	{GVK: schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}},
+}
+
+// Fake is an in-memory Backend. Reads, lists and type mapping go through
+// the same code as Client, on a fake dynamic client and a static REST
+// mapper. Dry-run applies merge the applied object onto the stored one
+// without defaulting, validation or conflict detection.
+type Fake struct {
+	*Client
+	// DryRunError, if set, is returned by ServerSideApplyDryRun, e.g. to
+	// simulate an identity that may not patch.
+	DryRunError error
+}
+
+// NewFake returns a Fake storing objects, with namespace as the default
+// namespace. It serves DefaultFakeKinds and the extra kinds given.
+func NewFake(namespace string, kinds []FakeKind, objects ...*unstructured.Unstructured) *Fake {
+	kinds = append(append([]FakeKind{}, DefaultFakeKinds...), kinds...)
+	// The group versions let kinds be mapped without a version, as the
+	// discovery-based mapper allows.
+	var versions []schema.GroupVersion
+	for _, k := range kinds {
+		versions = append(versions, k.GVK.GroupVersion())
+	}
+	mapper := meta.NewDefaultRESTMapper(versions)
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for _, k := range kinds {
+		scope := meta.RESTScopeRoot
+		if k.Namespaced {
+			scope = meta.RESTScopeNamespace
+		}
+		mapper.Add(k.GVK, scope)
+		plural, _ := meta.UnsafeGuessKindToResource(k.GVK)
+		listKinds[plural] = k.GVK.Kind + "List"
+	}
+
+	objs := make([]runtime.Object, len(objects))
+	for i, obj := range objects {
+		objs[i] = obj.DeepCopy()
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objs...)
+
+	return &Fake{Client: &Client{
+		dynamicClient: dynamicClient,
+		mapper:        mapper,
+		namespace:     namespace,
+	}}
+}
+
+// ServerSideApplyDryRun predicts an apply by merging local onto the stored
+// object: maps are merged recursively, other values are replaced. Like a
+// server, it fails with NotFound if local's namespace doesn't exist.
+func (f *Fake) ServerSideApplyDryRun(ctx context.Context, local *unstructured.Unstructured, fieldManager string, force bool) (*unstructured.Unstructured, error) {
+	if f.DryRunError != nil {
+		return nil, f.DryRunError
+	}
+
+	gvk := local.GroupVersionKind()
+	namespaced, err := f.IsNamespaced(gvk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map GVK %s: %w", gvk, err)
+	}
+	namespace := ""
+	if namespaced {
+		namespace = local.GetNamespace()
+		if namespace == "" {
+			namespace = f.namespace
+		}
+		if _, err := f.GetResource(ctx, "v1", "Namespace", namespace, ""); err != nil {
+			return nil, fmt.Errorf("dry-run apply failed: %w", err)
+		}
+	}
+
+	live, err := f.GetResource(ctx, local.GetAPIVersion(), gvk.Kind, local.GetName(), namespace)
+	if apierrors.IsNotFound(err) {
+		created := local.DeepCopy()
+		created.SetNamespace(namespace)
+		return created, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dry-run apply failed: %w", err)
+	}
+
+	merged := live.DeepCopy()
+	mergeMaps(merged.Object, local.DeepCopy().Object)
+	return merged, nil
+}
+
+// mergeMaps merges src into dst: nested maps are merged, other values of
+// src replace those of dst.
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
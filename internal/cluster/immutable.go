@@ -0,0 +1,52 @@
+package cluster
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FieldRejection is a field the API server refused to update.
+type FieldRejection struct {
+	// Field is the field path, e.g. spec.selector.
+	Field string
+	// Reason is the server's explanation, without the rejected value.
+	Reason string
+	// Type is the kind of failure, e.g. FieldValueInvalid. Unlike Reason, it
+	// never quotes the request.
+	Type metav1.CauseType
+}
+
+// immutablePattern matches the validation messages of fields that can't be
+// updated, e.g. "field is immutable" or "may not change once set".
+var immutablePattern = regexp.MustCompile(`(?i)immutable|may not change|can not be less than|updates to .* are forbidden`)
+
+// ImmutableRejections extracts the fields an apply was rejected for because
+// they can't be updated. It reports false if err is not such a rejection.
+func ImmutableRejections(err error) ([]FieldRejection, bool) {
+	if !apierrors.IsInvalid(err) {
+		return nil, false
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil, false
+	}
+
+	var rejections []FieldRejection
+	for _, cause := range status.Status().Details.Causes {
+		if !immutablePattern.MatchString(cause.Message) {
+			continue
+		}
+		// Messages read `Invalid value: "10.0.0.2": may not change once set`;
+		// the value is dropped so secrets can't leak into the output.
+		reason := cause.Message
+		if i := strings.LastIndex(reason, ": "); i >= 0 {
+			reason = reason[i+2:]
+		}
+		rejections = append(rejections, FieldRejection{Field: cause.Field, Reason: reason, Type: cause.Type})
+	}
+	return rejections, len(rejections) > 0
+}
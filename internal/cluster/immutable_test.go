@@ -0,0 +1,31 @@
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestImmutableRejections(t *testing.T) {
+	gk := schema.GroupKind{Kind: "Service"}
+	err := fmt.Errorf("failed to server-side dry-run apply: %w", apierrors.NewInvalid(gk, "api", field.ErrorList{
+		field.Invalid(field.NewPath("spec", "clusterIPs").Index(0), "10.0.0.2", "may not change once set"),
+		field.Required(field.NewPath("spec", "ports"), ""),
+	}))
+
+	got, ok := ImmutableRejections(err)
+	want := []FieldRejection{{Field: "spec.clusterIPs[0]", Reason: "may not change once set", Type: metav1.CauseTypeFieldValueInvalid}}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("ImmutableRejections() = %v, %v, want %v, true", got, ok, want)
+	}
+
+	other := apierrors.NewInvalid(gk, "api", field.ErrorList{field.Required(field.NewPath("spec", "ports"), "")})
+	if _, ok := ImmutableRejections(other); ok {
+		t.Error("ImmutableRejections() = true for a rejection of mutable fields")
+	}
+}
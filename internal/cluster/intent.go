@@ -0,0 +1,218 @@
+package cluster
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/structured-merge-diff/v6/fieldpath"
+)
+
+// listKeyCandidates are fields that commonly identify the items of Kubernetes
+// lists (their merge keys), in order of preference.
+var listKeyCandidates = []string{"name", "containerPort", "port", "mountPath", "devicePath", "key", "type", "ip", "topologyKey"}
+
+// ProjectOnto returns a copy of live reduced to the fields that local
+// declares. Server defaults and fields set by other managers (controllers,
+// webhooks, autoscalers) that local doesn't mention are dropped, so comparing
+// the result with local shows only drift in the declared fields.
+//
+// Lists of objects are matched item by item on a key field (such as name or
+// containerPort) when the local items have a unique one, positionally when
+// both lists have the same length, and are otherwise kept whole. Live items
+// without a local counterpart are dropped.
+func ProjectOnto(live, local *unstructured.Unstructured) *unstructured.Unstructured {
+	projected, _ := projectValue(live.Object, local.Object)
+	obj, _ := projected.(map[string]interface{})
+	if obj == nil {
+		obj = map[string]interface{}{}
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+// ProjectOwned is ProjectOnto respecting field ownership. Declared fields
+// that live's managedFields show owned by other managers but not by manager,
+// such as replicas scaled by an autoscaler, are left out of both the
+// projection and the returned copy of local, and returned with their owners
+// sorted by field. Hand edits take fields over too, so callers should report
+// the fields rather than hide them.
+//
+// If manager owns no field of live, for example because the object was
+// applied under another manager name, ownership can't tell declared fields
+// apart and only the projection is made.
+func ProjectOwned(live, local *unstructured.Unstructured, manager string) (projected, declared *unstructured.Unstructured, foreign []FieldOwners, err error) {
+	projected, declared = ProjectOnto(live.DeepCopy(), local), local.DeepCopy()
+
+	ours := &fieldpath.Set{}
+	owners := make(map[string][]string)
+	paths := make(map[string]fieldpath.Path)
+	for _, entry := range live.GetManagedFields() {
+		set, err := managedFieldSet(entry)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if set == nil {
+			continue
+		}
+		if entry.Manager == manager {
+			ours = ours.Union(set)
+			continue
+		}
+		label := managerLabel(entry)
+		set.Leaves().Iterate(func(path fieldpath.Path) {
+			if _, ok := lookupField(local.Object, path); !ok {
+				return
+			}
+			field := path.String()
+			owners[field] = append(owners[field], label)
+			paths[field] = path.Copy()
+		})
+	}
+	if ours.Empty() {
+		return projected, declared, nil, nil
+	}
+
+	for field, managers := range owners {
+		path := paths[field]
+		if ours.Has(path) {
+			continue
+		}
+		projected.Object = removeField(projected.Object, path).(map[string]interface{})
+		declared.Object = removeField(declared.Object, path).(map[string]interface{})
+		foreign = append(foreign, FieldOwners{Field: field, Managers: managers})
+	}
+	sort.Slice(foreign, func(i, j int) bool { return foreign[i].Field < foreign[j].Field })
+	return projected, declared, foreign, nil
+}
+
+// removeField removes the value at a managed-fields path from obj, and
+// returns obj. Maps are changed in place.
+func removeField(obj interface{}, path fieldpath.Path) interface{} {
+	if len(path) == 0 {
+		return obj
+	}
+	pe := path[0]
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		if pe.FieldName == nil {
+			return obj
+		}
+		child, ok := v[*pe.FieldName]
+		switch {
+		case !ok:
+		case len(path) == 1:
+			delete(v, *pe.FieldName)
+		default:
+			v[*pe.FieldName] = removeField(child, path[1:])
+		}
+		return v
+	case []interface{}:
+		if pe.FieldName != nil {
+			return obj
+		}
+		out := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			selected := pe.Index != nil && *pe.Index == i || pe.Index == nil && matchesElement(item, pe)
+			switch {
+			case !selected:
+				out = append(out, item)
+			case len(path) > 1:
+				out = append(out, removeField(item, path[1:]))
+			}
+		}
+		return out
+	}
+	return obj
+}
+
+// projectValue projects a live value onto the shape of a local one. It
+// reports false if the live value has nothing at that position.
+func projectValue(live, local interface{}) (interface{}, bool) {
+	if live == nil {
+		return nil, false
+	}
+
+	switch localV := local.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return live, true
+		}
+		out := make(map[string]interface{}, len(localV))
+		for key, localChild := range localV {
+			if v, ok := projectValue(liveMap[key], localChild); ok {
+				out[key] = v
+			}
+		}
+		return out, true
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok {
+			return live, true
+		}
+		return projectList(liveList, localV), true
+	default:
+		return live, true
+	}
+}
+
+// projectList projects the items of a live list onto the items of a local one.
+func projectList(live, local []interface{}) []interface{} {
+	if key := listKey(local); key != "" {
+		out := make([]interface{}, 0, len(local))
+		for _, localItem := range local {
+			want := localItem.(map[string]interface{})[key]
+			for _, liveItem := range live {
+				if m, ok := liveItem.(map[string]interface{}); ok && m[key] == want {
+					v, _ := projectValue(m, localItem)
+					out = append(out, v)
+					break
+				}
+			}
+		}
+		return out
+	}
+
+	if len(live) == len(local) {
+		out := make([]interface{}, len(live))
+		for i := range live {
+			out[i], _ = projectValue(live[i], local[i])
+		}
+		return out
+	}
+
+	// Scalar lists and unmatched object lists are atomic.
+	return live
+}
+
+// listKey returns the first candidate key present in every item of a list of
+// objects with unique values, or "" if there is none.
+func listKey(items []interface{}) string {
+	if len(items) == 0 {
+		return ""
+	}
+	for _, key := range listKeyCandidates {
+		seen := make(map[interface{}]bool, len(items))
+		unique := true
+		for _, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			v, ok := m[key]
+			// Only unique scalar values can be compared as keys.
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				ok = false
+			}
+			if !ok || seen[v] {
+				unique = false
+				break
+			}
+			seen[v] = true
+		}
+		if unique {
+			return key
+		}
+	}
+	return ""
+}
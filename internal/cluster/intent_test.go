@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestProjectOnto(t *testing.T) {
+	local := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Deployment",
+		"metadata": map[string]interface{}{"name": "api"},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "api", "image": "api:1.2", "args": []interface{}{"--port=80"}},
+				},
+			}},
+		},
+	}}
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "api",
+			"uid":             "1234",
+			"resourceVersion": "42",
+		},
+		"spec": map[string]interface{}{
+			"replicas":             int64(5),
+			"revisionHistoryLimit": int64(10),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{
+					// Injected by a webhook, not declared locally.
+					map[string]interface{}{"name": "sidecar", "image": "proxy:1"},
+					map[string]interface{}{"name": "api", "image": "api:1.2", "imagePullPolicy": "IfNotPresent", "args": []interface{}{"--port=80", "--debug"}},
+				},
+			}},
+		},
+		"status": map[string]interface{}{"readyReplicas": int64(5)},
+	}}
+
+	want := map[string]interface{}{
+		"kind":     "Deployment",
+		"metadata": map[string]interface{}{"name": "api"},
+		"spec": map[string]interface{}{
+			"replicas": int64(5),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "api", "image": "api:1.2", "args": []interface{}{"--port=80", "--debug"}},
+				},
+			}},
+		},
+	}
+
+	got := ProjectOnto(live, local).Object
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProjectOnto() = %#v, want %#v", got, want)
+	}
+}
+
+func TestProjectOntoMissingField(t *testing.T) {
+	local := &unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{"a": "1", "b": "2"},
+	}}
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{"a": "1"},
+	}}
+
+	want := map[string]interface{}{"data": map[string]interface{}{"a": "1"}}
+	if got := ProjectOnto(live, local).Object; !reflect.DeepEqual(got, want) {
+		t.Errorf("ProjectOnto() = %#v, want %#v", got, want)
+	}
+}
+
+func TestProjectOwned(t *testing.T) {
+	local := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "api", "image": "api:1.2"},
+				},
+			}},
+		},
+	}}
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(7),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "api", "image": "api:1.3"},
+				},
+			}},
+		},
+	}}
+	live.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			Manager:    "kdiff",
+			Operation:  metav1.ManagedFieldsOperationApply,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"api\"}":{".":{},"f:image":{},"f:name":{}}}}}}}`)},
+		},
+		{
+			Manager:     "kube-controller-manager",
+			Operation:   metav1.ManagedFieldsOperationUpdate,
+			Subresource: "scale",
+			FieldsType:  "FieldsV1",
+			FieldsV1:    &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+		},
+	})
+
+	projected, declared, foreign, err := ProjectOwned(live, local, "kdiff")
+	if err != nil {
+		t.Fatalf("ProjectOwned() error = %v", err)
+	}
+	wantForeign := []FieldOwners{{Field: ".spec.replicas", Managers: []string{"kube-controller-manager (Update scale)"}}}
+	if !reflect.DeepEqual(foreign, wantForeign) {
+		t.Errorf("ProjectOwned() foreign = %v, want %v", foreign, wantForeign)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(projected.Object, "spec", "replicas"); found {
+		t.Errorf("ProjectOwned() projection kept the autoscaler's replicas: %v", projected.Object)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(declared.Object, "spec", "replicas"); found {
+		t.Errorf("ProjectOwned() local copy kept the autoscaler's replicas: %v", declared.Object)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(local.Object, "spec", "replicas"); !found {
+		t.Error("ProjectOwned() modified local")
+	}
+	containers, _, _ := unstructured.NestedSlice(projected.Object, "spec", "template", "spec", "containers")
+	if want := []interface{}{map[string]interface{}{"name": "api", "image": "api:1.3"}}; !reflect.DeepEqual(containers, want) {
+		t.Errorf("ProjectOwned() containers = %v, want %v", containers, want)
+	}
+
+	// Without fields of its own, the manager's declared fields are unknown.
+	_, declared, foreign, err = ProjectOwned(live, local, "helm")
+	if err != nil {
+		t.Fatalf("ProjectOwned() error = %v", err)
+	}
+	if foreign != nil || !reflect.DeepEqual(declared.Object, local.Object) {
+		t.Errorf("ProjectOwned() for an unknown manager = %v, %v, want local unchanged", declared.Object, foreign)
+	}
+}
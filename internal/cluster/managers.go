@@ -0,0 +1,177 @@
+package cluster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/structured-merge-diff/v6/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v6/value"
+)
+
+// DefaultFieldManager is the field manager dry-run applies are made as
+// unless another is given.
+const DefaultFieldManager = "kdiff"
+
+// FieldConflict is a field an apply would take over from another manager.
+type FieldConflict struct {
+	// Field is the field path, e.g. .spec.replicas.
+	Field string
+	// Manager is the field manager that owns the field today.
+	Manager string
+}
+
+// FieldOwners lists the managers owning a field that an apply changes.
+type FieldOwners struct {
+	// Field is the field path, e.g. .spec.template.spec.containers[name="api"].image.
+	Field string
+	// Managers are the owning managers, with their operation, e.g. "kubectl-edit (Update)".
+	Managers []string
+}
+
+// conflictManagerPattern extracts the manager from a conflict cause message,
+// e.g. `conflict with "kube-controller-manager" using apps/v1`.
+var conflictManagerPattern = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// ApplyConflicts extracts the conflicting fields from the error of an apply
+// made without force. It reports false if err is not an apply conflict.
+func ApplyConflicts(err error) ([]FieldConflict, bool) {
+	if !apierrors.IsConflict(err) {
+		return nil, false
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil, false
+	}
+
+	var conflicts []FieldConflict
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflict := FieldConflict{Field: cause.Field, Manager: cause.Message}
+		if m := conflictManagerPattern.FindStringSubmatch(cause.Message); m != nil {
+			conflict.Manager = m[1]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts, len(conflicts) > 0
+}
+
+// ChangedFieldOwners returns the fields recorded in live's managedFields
+// whose values differ in target, with the managers owning them today.
+// Results are sorted by field.
+func ChangedFieldOwners(live, target *unstructured.Unstructured) ([]FieldOwners, error) {
+	owners := make(map[string][]string)
+	for _, entry := range live.GetManagedFields() {
+		set, err := managedFieldSet(entry)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			continue
+		}
+
+		manager := managerLabel(entry)
+		set.Leaves().Iterate(func(path fieldpath.Path) {
+			before, inLive := lookupField(live.Object, path)
+			after, inTarget := lookupField(target.Object, path)
+			if inLive != inTarget || (inLive && !value.Equals(value.NewValueInterface(before), value.NewValueInterface(after))) {
+				field := path.String()
+				owners[field] = append(owners[field], manager)
+			}
+		})
+	}
+
+	result := make([]FieldOwners, 0, len(owners))
+	for field, managers := range owners {
+		result = append(result, FieldOwners{Field: field, Managers: managers})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Field < result[j].Field })
+	return result, nil
+}
+
+// managedFieldSet parses the fields of a managedFields entry. It returns nil
+// for entries in an unknown format.
+func managedFieldSet(entry metav1.ManagedFieldsEntry) (*fieldpath.Set, error) {
+	if entry.FieldsType != "FieldsV1" || entry.FieldsV1 == nil {
+		return nil, nil
+	}
+	set := &fieldpath.Set{}
+	if err := set.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+		return nil, fmt.Errorf("failed to parse managed fields of %s: %w", entry.Manager, err)
+	}
+	return set, nil
+}
+
+// managerLabel names a managedFields entry with its operation, e.g.
+// "kube-controller-manager (Update scale)".
+func managerLabel(entry metav1.ManagedFieldsEntry) string {
+	if entry.Subresource != "" {
+		return fmt.Sprintf("%s (%s %s)", entry.Manager, entry.Operation, entry.Subresource)
+	}
+	return fmt.Sprintf("%s (%s)", entry.Manager, entry.Operation)
+}
+
+// lookupField resolves a managed-fields path in an object.
+func lookupField(obj interface{}, path fieldpath.Path) (interface{}, bool) {
+	current := obj
+	for _, pe := range path {
+		switch {
+		case pe.FieldName != nil:
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = m[*pe.FieldName]; !ok {
+				return nil, false
+			}
+		case pe.Index != nil:
+			list, ok := current.([]interface{})
+			if !ok || *pe.Index >= len(list) {
+				return nil, false
+			}
+			current = list[*pe.Index]
+		default:
+			list, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			found := false
+			for _, item := range list {
+				if matchesElement(item, pe) {
+					current, found = item, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		}
+	}
+	return current, true
+}
+
+// matchesElement reports whether a list item is selected by a Key or Value
+// path element.
+func matchesElement(item interface{}, pe fieldpath.PathElement) bool {
+	if pe.Value != nil {
+		return value.Equals(value.NewValueInterface(item), *pe.Value)
+	}
+	m, ok := item.(map[string]interface{})
+	if !ok || pe.Key == nil {
+		return false
+	}
+	for _, field := range *pe.Key {
+		v, ok := m[field.Name]
+		if !ok || !value.Equals(value.NewValueInterface(v), field.Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestApplyConflicts(t *testing.T) {
+	err := fmt.Errorf("dry-run apply failed: %w", apierrors.NewApplyConflict([]metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldManagerConflict,
+		Message: `conflict with "kube-controller-manager" using apps/v1`,
+		Field:   ".spec.replicas",
+	}}, "Apply failed with 1 conflict"))
+
+	got, ok := ApplyConflicts(err)
+	want := []FieldConflict{{Field: ".spec.replicas", Manager: "kube-controller-manager"}}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyConflicts() = %v, %v, want %v, true", got, ok, want)
+	}
+
+	if _, ok := ApplyConflicts(apierrors.NewBadRequest("invalid")); ok {
+		t.Error("ApplyConflicts() = true for a non-conflict error")
+	}
+}
+
+func TestChangedFieldOwners(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "api", "image": "api:1.2"},
+				},
+			}},
+		},
+	}}
+	live.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			Manager:    "kubectl",
+			Operation:  metav1.ManagedFieldsOperationApply,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"api\"}":{".":{},"f:image":{},"f:name":{}}}}}}}`)},
+		},
+		{
+			Manager:    "kube-controller-manager",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+		},
+	})
+
+	target := live.DeepCopy()
+	unstructured.SetNestedField(target.Object, int64(5), "spec", "replicas")
+	unstructured.SetNestedSlice(target.Object, []interface{}{
+		map[string]interface{}{"name": "api", "image": "api:1.3"},
+	}, "spec", "template", "spec", "containers")
+
+	got, err := ChangedFieldOwners(live, target)
+	if err != nil {
+		t.Fatalf("ChangedFieldOwners() error = %v", err)
+	}
+	want := []FieldOwners{
+		{Field: ".spec.replicas", Managers: []string{"kube-controller-manager (Update)"}},
+		{Field: `.spec.template.spec.containers[name="api"].image`, Managers: []string{"kubectl (Apply)"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFieldOwners() = %v, want %v", got, want)
+	}
+}
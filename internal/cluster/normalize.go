@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ServerPopulatedFields are set by the API server and never declared in
+// manifests. They are ignored when comparing live objects with local ones.
+var ServerPopulatedFields = [][]string{
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "selfLink"},
+	{"status"},
+}
+
+// ServerPopulatedAnnotations are annotations written by clients and
+// controllers rather than declared in manifests.
+var ServerPopulatedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+}
+
+// Normalize returns a copy of obj without server-populated fields and
+// annotations, so live and local objects can be compared offline.
+func Normalize(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	out := obj.DeepCopy()
+	for _, path := range ServerPopulatedFields {
+		unstructured.RemoveNestedField(out.Object, path...)
+	}
+
+	if annotations := out.GetAnnotations(); annotations != nil {
+		for _, key := range ServerPopulatedAnnotations {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(out.Object, "metadata", "annotations")
+		} else {
+			out.SetAnnotations(annotations)
+		}
+	}
+	return out
+}
+
+// ClusterAssignedFields are assigned by a cluster when an object is created,
+// so they differ between clusters holding the same manifest.
+var ClusterAssignedFields = map[schema.GroupKind][][]string{
+	{Kind: "Service"}: {
+		{"spec", "clusterIP"},
+		{"spec", "clusterIPs"},
+		{"spec", "healthCheckNodePort"},
+	},
+	{Kind: "PersistentVolumeClaim"}: {
+		{"spec", "volumeName"},
+	},
+}
+
+// ClusterAssignedAnnotations are written by a cluster's controllers and
+// differ between clusters holding the same manifest.
+var ClusterAssignedAnnotations = []string{
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+// NormalizeAcrossClusters is like Normalize, but also strips fields and
+// annotations assigned by the cluster, so the same object can be compared
+// between two clusters.
+func NormalizeAcrossClusters(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	out := Normalize(obj)
+	for _, path := range ClusterAssignedFields[out.GroupVersionKind().GroupKind()] {
+		unstructured.RemoveNestedField(out.Object, path...)
+	}
+
+	if annotations := out.GetAnnotations(); annotations != nil {
+		for _, key := range ClusterAssignedAnnotations {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(out.Object, "metadata", "annotations")
+		} else {
+			out.SetAnnotations(annotations)
+		}
+	}
+	return out
+}
+
+// IsDryRunUnavailable reports whether a server-side dry-run apply failed
+// because the cluster or the caller's permissions don't allow it, rather than
+// because the manifest is invalid. A client-side diff can be used instead.
+// Bad requests and internal errors (e.g. a failing admission webhook) are not
+// included: the apply would fail the same way, which a client-side diff would
+// hide.
+func IsDryRunUnavailable(err error) bool {
+	return apierrors.IsForbidden(err) ||
+		apierrors.IsMethodNotSupported(err) ||
+		apierrors.IsUnsupportedMediaType(err) ||
+		apierrors.IsNotAcceptable(err)
+}
+
+// ErrorStatus describes an API error by its status reason and code, e.g.
+// "Forbidden, 403", leaving out the message, which may quote field values.
+func ErrorStatus(err error) string {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return "unknown error"
+	}
+	reason := status.Status().Reason
+	if reason == "" {
+		reason = metav1.StatusReasonUnknown
+	}
+	return fmt.Sprintf("%s, %d", reason, status.Status().Code)
+}
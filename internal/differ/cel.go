@@ -0,0 +1,65 @@
+package differ
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// whereExpression is a compiled CEL expression evaluated against each resource.
+// The resource is bound to the variable "object".
+type whereExpression struct {
+	source  string
+	program cel.Program
+}
+
+// compileWhere compiles a CEL expression, which must evaluate to a bool.
+func compileWhere(expr string) (*whereExpression, error) {
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid where expression %q: %w", expr, issues.Err())
+	}
+	if out := ast.OutputType(); out != cel.BoolType && out != cel.DynType {
+		return nil, fmt.Errorf("where expression %q must evaluate to bool, not %s", expr, out)
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression %q: %w", expr, err)
+	}
+	return &whereExpression{source: expr, program: program}, nil
+}
+
+// matches evaluates the expression against a resource. It returns an error
+// if the evaluation fails, e.g. by selecting a field the resource doesn't
+// have, or doesn't return a bool: treating either as no match would silently
+// hide resources. Optional fields can be guarded with has() or in.
+func (w *whereExpression) matches(doc interface{}) (bool, error) {
+	out, _, err := w.program.Eval(map[string]interface{}{"object": doc})
+	if err != nil {
+		return false, fmt.Errorf("where expression %q failed on %s: %w", w.source, describeResource(doc), err)
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("where expression %q returned %s for %s, not a bool", w.source, out.Type().TypeName(), describeResource(doc))
+	}
+	return matched, nil
+}
+
+// describeResource identifies a decoded resource in error messages.
+func describeResource(doc interface{}) string {
+	m := asStringMap(doc)
+	kind, _ := m["kind"].(string)
+	metadata := asStringMap(m["metadata"])
+	namespace, _ := metadata["namespace"].(string)
+	name, _ := metadata["name"].(string)
+	if namespace != "" {
+		name = namespace + "/" + name
+	}
+	return kind + " " + name
+}
@@ -0,0 +1,234 @@
+package differ
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ChangeType classifies how a resource differs between two inputs.
+type ChangeType string
+
+const (
+	// ChangeAdded is a resource only in the second input.
+	ChangeAdded ChangeType = "added"
+	// ChangeRemoved is a resource only in the first input.
+	ChangeRemoved ChangeType = "removed"
+	// ChangeModified is a resource in both inputs with different content.
+	ChangeModified ChangeType = "modified"
+	// ChangeUnchanged is a resource in both inputs with equal content.
+	ChangeUnchanged ChangeType = "unchanged"
+)
+
+// ResourceChange is one resource as found in each of two inputs.
+type ResourceChange struct {
+	Type       ChangeType
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Before is the resource in the first input, nil if it was added.
+	Before map[string]interface{}
+	// After is the resource in the second input, nil if it was removed.
+	After map[string]interface{}
+}
+
+// Changes pairs the resources of two YAML inputs by API group, kind, namespace
+// and name, and classifies each pair. Filters and ignored fields apply as in
+// Diff, but values are never masked. Changes are ordered as the second input,
+// followed by the removed resources in the order of the first.
+func Changes(fileA, fileB []byte, opts Options) ([]ResourceChange, error) {
+	docsA, err := decodeDocs(fileA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode first file: %w", err)
+	}
+	docsB, err := decodeDocs(fileB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode second file: %w", err)
+	}
+
+	filter, err := NewFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := newIgnorer(opts)
+	if err != nil {
+		return nil, err
+	}
+	sides, err := filterResources(filter, docsA, docsB)
+	if err != nil {
+		return nil, err
+	}
+	return pairResources(ignoreFields(sides[0], ignore), ignoreFields(sides[1], ignore)), nil
+}
+
+// pairResources pairs and classifies decoded resources, in the order
+// described by Changes.
+func pairResources(docsA, docsB []interface{}) []ResourceChange {
+	before := resourceMaps(docsA)
+	after := resourceMaps(docsB)
+
+	index := make(map[string]map[string]interface{}, len(before))
+	for _, obj := range before {
+		index[resourceKey(obj)] = obj
+	}
+
+	var changes []ResourceChange
+	paired := make(map[string]bool, len(after))
+	for _, obj := range after {
+		key := resourceKey(obj)
+		paired[key] = true
+		change := newResourceChange(obj)
+		change.After = obj
+		change.Type = ChangeAdded
+		if prev, ok := index[key]; ok {
+			change.Before = prev
+			change.Type = ChangeModified
+			if reflect.DeepEqual(prev, obj) {
+				change.Type = ChangeUnchanged
+			}
+		}
+		changes = append(changes, change)
+	}
+	for _, obj := range before {
+		if paired[resourceKey(obj)] {
+			continue
+		}
+		change := newResourceChange(obj)
+		change.Before = obj
+		change.Type = ChangeRemoved
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// newResourceChange returns a change identified by obj.
+func newResourceChange(obj map[string]interface{}) ResourceChange {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	metadata := asStringMap(obj["metadata"])
+	namespace, _ := metadata["namespace"].(string)
+	name, _ := metadata["name"].(string)
+	return ResourceChange{APIVersion: apiVersion, Kind: kind, Namespace: namespace, Name: name}
+}
+
+// resourceMaps returns the documents that are objects. The documents of empty
+// or scalar YAML files are dropped.
+func resourceMaps(docs []interface{}) []map[string]interface{} {
+	var objs []map[string]interface{}
+	for _, doc := range docs {
+		if obj := asStringMap(doc); obj != nil {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// resourceKey identifies a resource by API group, kind, namespace and name.
+func resourceKey(obj map[string]interface{}) string {
+	apiVersion, _ := obj["apiVersion"].(string)
+	group := ""
+	if i := strings.Index(apiVersion, "/"); i >= 0 {
+		group = apiVersion[:i]
+	}
+	kind, _ := obj["kind"].(string)
+	metadata := asStringMap(obj["metadata"])
+	namespace, _ := metadata["namespace"].(string)
+	name, _ := metadata["name"].(string)
+	return group + "/" + kind + "/" + namespace + "/" + name
+}
+
+// ResourceDiff is a resource change with its rendered diff.
+type ResourceDiff struct {
+	ResourceChange
+	// Diff is the uncolored unified diff of the resource, empty if it is
+	// unchanged.
+	Diff string
+}
+
+// DiffResources is like Changes, but masks resources in secure mode and
+// renders each change as its own unified diff. Like Diff, it refuses to
+// return output in which a masked value survived.
+func DiffResources(fileA, fileB []byte, opts Options) ([]ResourceDiff, error) {
+	docsA, err := decodeDocs(fileA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode first file: %w", err)
+	}
+	docsB, err := decodeDocs(fileB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode second file: %w", err)
+	}
+
+	filter, err := NewFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := newIgnorer(opts)
+	if err != nil {
+		return nil, err
+	}
+	sides, err := filterResources(filter, docsA, docsB)
+	if err != nil {
+		return nil, err
+	}
+	docsA = ignoreFields(sides[0], ignore)
+	docsB = ignoreFields(sides[1], ignore)
+
+	var maskerA, maskerB *masker
+	if opts.SecureMode {
+		maskerA, maskerB = newMaskerPair(opts, docsA, docsB)
+		maskSensitiveData(docsA, maskerA)
+		maskSensitiveData(docsB, maskerB)
+	}
+
+	var diffs []ResourceDiff
+	for _, change := range pairResources(docsA, docsB) {
+		d := ResourceDiff{ResourceChange: change}
+		if change.Type != ChangeUnchanged {
+			if d.Diff, err = unifiedDiff(change.Before, change.After); err != nil {
+				return nil, fmt.Errorf("failed to render %s %s: %w", change.Kind, change.Name, err)
+			}
+			if opts.SecureMode {
+				if err := verifyMasked(d.Diff, maskerA, maskerB); err != nil {
+					return nil, err
+				}
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// unifiedDiff renders the uncolored unified diff between two resources, either
+// of which may be nil.
+func unifiedDiff(before, after map[string]interface{}) (string, error) {
+	// SplitLines adds a line break to the last line, so the trailing one is
+	// trimmed first, and would turn an absent resource into one empty line.
+	lines := func(obj map[string]interface{}) ([]string, error) {
+		if obj == nil {
+			return nil, nil
+		}
+		text, err := marshalDocs([]interface{}{obj})
+		if err != nil {
+			return nil, err
+		}
+		return difflib.SplitLines(strings.TrimSuffix(text, "\n")), nil
+	}
+	linesA, err := lines(before)
+	if err != nil {
+		return "", err
+	}
+	linesB, err := lines(after)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        linesA,
+		B:        linesB,
+		FromFile: "Original",
+		ToFile:   "Modified",
+		Context:  3,
+	})
+}
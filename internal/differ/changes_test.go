@@ -0,0 +1,150 @@
+package differ
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	a := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata: {name: same}
+data: {a: "1"}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: changed}
+data: {a: "1"}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: removed}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: moved, namespace: a}
+`)
+	b := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata: {name: added}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: changed}
+data: {a: "2"}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: same}
+data: {a: "1"}
+---
+apiVersion: apps/v1beta1
+kind: Deployment
+metadata: {name: moved, namespace: b}
+`)
+
+	changes, err := Changes(a, b, Options{})
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, string(c.Type)+" "+c.Kind+" "+qualified(c.Namespace, c.Name))
+	}
+	want := []string{
+		"added ConfigMap added",
+		"modified ConfigMap changed",
+		"unchanged ConfigMap same",
+		"added Deployment b/moved",
+		"removed ConfigMap removed",
+		"removed Deployment a/moved",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() = %v, want %v", got, want)
+	}
+}
+
+func qualified(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+func TestDiffResources(t *testing.T) {
+	a := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata: {name: changed}\ndata: {a: \"1\"}\n")
+	b := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata: {name: changed}\ndata: {a: \"2\"}\n---\napiVersion: v1\nkind: ConfigMap\nmetadata: {name: added}\n")
+
+	diffs, err := DiffResources(a, b, Options{})
+	if err != nil {
+		t.Fatalf("DiffResources() error = %v", err)
+	}
+	want := []string{
+		"--- Original\n+++ Modified\n@@ -1,6 +1,6 @@\n apiVersion: v1\n data:\n-  a: \"1\"\n+  a: \"2\"\n kind: ConfigMap\n metadata:\n   name: changed\n",
+		"--- Original\n+++ Modified\n@@ -0,0 +1,4 @@\n+apiVersion: v1\n+kind: ConfigMap\n+metadata:\n+  name: added\n",
+	}
+	var got []string
+	for _, d := range diffs {
+		got = append(got, d.Diff)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffResources() diffs = %q, want %q", got, want)
+	}
+}
+
+func TestChangesFilterMatchesEitherSide(t *testing.T) {
+	a := []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata: {name: scaled, labels: {app: web}}
+spec: {replicas: 3}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: relabeled, labels: {app: web}}
+spec: {replicas: 1}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: small}
+spec: {replicas: 1}
+`)
+	b := []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata: {name: scaled, labels: {app: web}}
+spec: {replicas: 5}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: relabeled, labels: {app: api}}
+spec: {replicas: 1}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: small}
+spec: {replicas: 2}
+`)
+
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{name: "where", opts: Options{Where: `object.spec.replicas > 4`}, want: []string{"modified scaled"}},
+		{name: "selector", opts: Options{Selector: "app=api"}, want: []string{"modified relabeled"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Changes(a, b, tt.opts)
+			if err != nil {
+				t.Fatalf("Changes() error = %v", err)
+			}
+			var got []string
+			for _, c := range changes {
+				got = append(got, string(c.Type)+" "+c.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Changes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -15,12 +15,47 @@ import (
 type Options struct {
 	// SecureMode enables masking of sensitive data in Secrets and ConfigMaps.
 	SecureMode bool
+	// ScanSecrets enables heuristic detection of credentials (known token formats
+	// and high-entropy strings) in every string value of every resource.
+	// It only takes effect in SecureMode.
+	ScanSecrets bool
+	// MaskingRules defines which fields are masked per Kind (lowercased).
+	// If nil, DefaultMaskingRules is used.
+	MaskingRules map[string]MaskConfig
+	// MaskStrategy is the global mask strategy, used for detected secrets and
+	// for rules without their own strategy. Defaults to MaskHash.
+	MaskStrategy MaskStrategy
+	// MaskReveal is the number of characters MaskPartial reveals at each end
+	// of a value. Defaults to 4.
+	MaskReveal int
 	// IncludeKinds filters resources to only include specific Kinds (case-insensitive).
-	// If empty, all resources are included.
+	// Entries may be plurals or short names ("deploy") and may be qualified as
+	// group/Kind or group/version/Kind. If empty, all resources are included.
 	IncludeKinds []string
-	// ExcludeKinds filters resources to exclude specific Kinds (case-insensitive).
-	// If empty, no resources are excluded.
+	// ExcludeKinds filters resources to exclude specific Kinds, in the same forms
+	// as IncludeKinds. If empty, no resources are excluded.
 	ExcludeKinds []string
+	// KindResolver resolves kind filter tokens unknown to the built-in table,
+	// e.g. short names of custom resources. Optional.
+	KindResolver KindResolver
+	// Namespace filters resources to a single namespace. Resources without a
+	// namespace are kept. If empty, all namespaces are included.
+	Namespace string
+	// Names filters resources by metadata.name using glob patterns (e.g. "api-*").
+	// If empty, all names are included.
+	Names []string
+	// Selector filters resources by a Kubernetes label selector (e.g. "app=payments,tier!=cache").
+	// If empty, all resources are included.
+	Selector string
+	// Where filters resources by a CEL expression evaluated against each
+	// resource, bound to the variable "object" (e.g. `object.kind ==
+	// "Deployment" && object.spec.replicas > 3`). It must return a bool, and
+	// evaluation errors fail the comparison. If empty, all resources are
+	// included.
+	Where string
+	// IgnoreFields are removed from the resources that pass the filters
+	// before they are compared.
+	IgnoreFields []IgnoreRule
 }
 
 // Diff compares two YAML byte slices and returns a human-readable diff.
@@ -35,16 +70,28 @@ func Diff(fileA, fileB []byte, opts Options) (string, error) {
 		return "", fmt.Errorf("failed to decode second file: %w", err)
 	}
 
-	// Filter resources based on IncludeKinds and ExcludeKinds
-	if len(opts.IncludeKinds) > 0 || len(opts.ExcludeKinds) > 0 {
-		docsA = filterResources(docsA, opts.IncludeKinds, opts.ExcludeKinds)
-		docsB = filterResources(docsB, opts.IncludeKinds, opts.ExcludeKinds)
+	// Filter resources by Kind, namespace, name and labels
+	filter, err := NewFilter(opts)
+	if err != nil {
+		return "", err
+	}
+	ignore, err := newIgnorer(opts)
+	if err != nil {
+		return "", err
+	}
+	sides, err := filterResources(filter, docsA, docsB)
+	if err != nil {
+		return "", err
 	}
+	docsA = ignoreFields(sides[0], ignore)
+	docsB = ignoreFields(sides[1], ignore)
 
 	// Mask Sensitive Data
+	var maskerA, maskerB *masker
 	if opts.SecureMode {
-		maskSensitiveData(docsA, DefaultMaskingRules())
-		maskSensitiveData(docsB, DefaultMaskingRules())
+		maskerA, maskerB = newMaskerPair(opts, docsA, docsB)
+		maskSensitiveData(docsA, maskerA)
+		maskSensitiveData(docsB, maskerB)
 	}
 
 	// Normal Mode & Secure Mode (now that data is safe): Normalize and Diff
@@ -76,6 +123,13 @@ func Diff(fileA, fileB []byte, opts Options) (string, error) {
 		return "# No Changes", nil
 	}
 
+	// Secure Mode: refuse to print if any masked value survived rendering
+	if opts.SecureMode {
+		if err := verifyMasked(text, maskerA, maskerB); err != nil {
+			return "", err
+		}
+	}
+
 	// Colorize
 	return colorizeDiff(text), nil
 }
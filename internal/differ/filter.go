@@ -1,84 +1,230 @@
 package differ
 
 import (
+	"fmt"
+	"path"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
 )
 
-// filterResources filters documents based on inclusion and exclusion criteria.
-// It performs a single pass over the documents.
-func filterResources(docs []interface{}, includeKinds, excludeKinds []string) []interface{} {
-	// If no filters are applied, return original docs
-	if len(includeKinds) == 0 && len(excludeKinds) == 0 {
-		return docs
-	}
+// Filter selects resources by Kind, namespace, name, labels and CEL expression.
+// The zero value matches everything.
+type Filter struct {
+	includeKinds []kindSelector
+	excludeKinds []kindSelector
+	namespace    string
+	names        []string
+	selector     labels.Selector
+	where        *whereExpression
+}
 
-	// Prepare sets for O(1) loopups
-	includeSet := make(map[string]bool)
-	for _, k := range includeKinds {
-		includeSet[strings.ToLower(k)] = true
+// NewFilter builds a Filter from the filtering fields of opts.
+// It returns an error if a kind token, a name pattern, the label selector or
+// the CEL expression is malformed.
+func NewFilter(opts Options) (*Filter, error) {
+	f := &Filter{
+		namespace: opts.Namespace,
+		names:     opts.Names,
 	}
 
-	excludeSet := make(map[string]bool)
-	for _, k := range excludeKinds {
-		excludeSet[strings.ToLower(k)] = true
+	// Resolve kind tokens (short names, plurals, group-qualified forms) once
+	for _, k := range opts.IncludeKinds {
+		sel, err := parseKindSelector(k, opts.KindResolver)
+		if err != nil {
+			return nil, err
+		}
+		f.includeKinds = append(f.includeKinds, sel)
+	}
+	for _, k := range opts.ExcludeKinds {
+		sel, err := parseKindSelector(k, opts.KindResolver)
+		if err != nil {
+			return nil, err
+		}
+		f.excludeKinds = append(f.excludeKinds, sel)
 	}
 
-	var filtered []interface{}
+	for _, pattern := range opts.Names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+	}
 
-	for _, doc := range docs {
-		// Extract Kind
-		var kind string
-		foundKind := false
+	if opts.Selector != "" {
+		selector, err := labels.Parse(opts.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %w", opts.Selector, err)
+		}
+		f.selector = selector
+	}
 
-		if m, ok := doc.(map[string]interface{}); ok {
-			if kVal, ok := m["kind"]; ok {
-				if kStr, ok := kVal.(string); ok {
-					kind = strings.ToLower(kStr)
-					foundKind = true
-				}
-			}
-		} else if mg, ok := doc.(map[interface{}]interface{}); ok {
-			// Robust fallback
-			if kVal, ok := mg["kind"]; ok {
-				if kStr, ok := kVal.(string); ok {
-					kind = strings.ToLower(kStr)
-					foundKind = true
-				}
-			}
+	if opts.Where != "" {
+		where, err := compileWhere(opts.Where)
+		if err != nil {
+			return nil, err
 		}
+		f.where = where
+	}
 
-		if !foundKind {
-			// If we can't identify the kind, we treat it as "unknown".
-			// Policy: If inclusion filter is active, drop unknowns?
-			// Or keep them?
-			// Usually strict filtering implies dropping things that don't match.
-			if len(includeSet) > 0 {
-				continue
-			}
-			// If only exclusion is active, and we don't know the kind, we can't exclude it, so keep it.
-			filtered = append(filtered, doc)
-			continue
+	return f, nil
+}
+
+// Empty reports whether the filter matches every resource.
+func (f *Filter) Empty() bool {
+	return len(f.includeKinds) == 0 && len(f.excludeKinds) == 0 &&
+		f.namespace == "" && len(f.names) == 0 && f.selector == nil && f.where == nil
+}
+
+// Match reports whether a decoded resource passes the filter. It returns an
+// error if the CEL expression fails on the resource.
+//
+// Resources without a Kind are dropped when an inclusion filter is active.
+// Resources without metadata.namespace always pass the namespace filter, since
+// they are either cluster-scoped or take their namespace at apply time.
+func (f *Filter) Match(doc interface{}) (bool, error) {
+	m := asStringMap(doc)
+
+	// Extract Kind and apiVersion
+	kind, foundKind := "", false
+	apiVersion := ""
+	if m != nil {
+		if kStr, ok := m["kind"].(string); ok {
+			kind = strings.ToLower(kStr)
+			foundKind = true
 		}
+		apiVersion, _ = m["apiVersion"].(string)
+	}
 
+	if !foundKind {
+		// If we can't identify the kind, we treat it as "unknown".
+		// Strict filtering implies dropping things that don't match an allowlist.
+		if len(f.includeKinds) > 0 {
+			return false, nil
+		}
+	} else {
 		// 1. Check Inclusion
-		if len(includeSet) > 0 {
-			if !includeSet[kind] {
-				// Not in allowlist -> Drop
-				continue
-			}
+		if len(f.includeKinds) > 0 && !matchesAnyKind(f.includeKinds, apiVersion, kind) {
+			// Not in allowlist -> Drop
+			return false, nil
 		}
 
 		// 2. Check Exclusion
-		if len(excludeSet) > 0 {
-			if excludeSet[kind] {
-				// In denylist -> Drop
-				continue
+		if matchesAnyKind(f.excludeKinds, apiVersion, kind) {
+			// In denylist -> Drop
+			return false, nil
+		}
+	}
+
+	if f.namespace == "" && len(f.names) == 0 && f.selector == nil && f.where == nil {
+		return true, nil
+	}
+
+	metadata := asStringMap(m["metadata"])
+
+	// 3. Check Namespace
+	if f.namespace != "" {
+		if ns, _ := metadata["namespace"].(string); ns != "" && ns != f.namespace {
+			return false, nil
+		}
+	}
+
+	// 4. Check Name
+	if len(f.names) > 0 {
+		name, _ := metadata["name"].(string)
+		matched := false
+		for _, pattern := range f.names {
+			if ok, _ := path.Match(pattern, name); ok {
+				matched = true
+				break
 			}
 		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	// 5. Check Labels
+	if f.selector != nil {
+		set := labels.Set{}
+		for k, v := range asStringMap(metadata["labels"]) {
+			set[k] = fmt.Sprintf("%v", v)
+		}
+		if !f.selector.Matches(set) {
+			return false, nil
+		}
+	}
 
-		// Passed both checks
-		filtered = append(filtered, doc)
+	// 6. Check CEL expression
+	if f.where != nil {
+		return f.where.matches(doc)
 	}
 
-	return filtered
+	return true, nil
+}
+
+// matchesAnyKind reports whether any selector matches the resource type.
+func matchesAnyKind(selectors []kindSelector, apiVersion, kind string) bool {
+	for _, sel := range selectors {
+		if sel.matches(apiVersion, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterResources filters the documents of each side of a comparison. A
+// resource is kept on every side if it passes the filter on any side, so one
+// whose labels or fields cross the filter (e.g. replicas going from 3 to 5
+// with a "> 4" expression) is compared as modified rather than reported as
+// added or removed. Documents that aren't objects are filtered on their own.
+func filterResources(f *Filter, sides ...[]interface{}) ([][]interface{}, error) {
+	// If no filters are applied, return original docs
+	if f.Empty() {
+		return sides, nil
+	}
+
+	kept := make(map[string]bool)
+	matched := make([][]bool, len(sides))
+	for i, docs := range sides {
+		matched[i] = make([]bool, len(docs))
+		for j, doc := range docs {
+			ok, err := f.Match(doc)
+			if err != nil {
+				return nil, err
+			}
+			matched[i][j] = ok
+			if obj := asStringMap(doc); ok && obj != nil {
+				kept[resourceKey(obj)] = true
+			}
+		}
+	}
+
+	filtered := make([][]interface{}, len(sides))
+	for i, docs := range sides {
+		for j, doc := range docs {
+			obj := asStringMap(doc)
+			if matched[i][j] || (obj != nil && kept[resourceKey(obj)]) {
+				filtered[i] = append(filtered[i], doc)
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// asStringMap returns v as a map[string]interface{}, converting generic maps.
+// It returns nil if v is not a map.
+func asStringMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		// Robust fallback
+		converted := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			converted[fmt.Sprintf("%v", k)] = val
+		}
+		return converted
+	default:
+		return nil
+	}
 }
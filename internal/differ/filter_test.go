@@ -0,0 +1,180 @@
+package differ
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func resource(kind, namespace, name string, labels map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata":   metadata,
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	payments := resource("Deployment", "payments", "api-server", map[string]interface{}{"app": "payments", "tier": "web"})
+	cache := resource("Deployment", "payments", "redis", map[string]interface{}{"app": "payments", "tier": "cache"})
+	noNamespace := resource("Service", "", "api-server", map[string]interface{}{"app": "payments"})
+	other := resource("Service", "billing", "billing-api", map[string]interface{}{"app": "billing"})
+
+	tests := []struct {
+		name string
+		opts Options
+		doc  interface{}
+		want bool
+	}{
+		{name: "Empty filter", opts: Options{}, doc: payments, want: true},
+		{name: "Include kind", opts: Options{IncludeKinds: []string{"deployment"}}, doc: payments, want: true},
+		{name: "Include kind mismatch", opts: Options{IncludeKinds: []string{"Service"}}, doc: payments, want: false},
+		{name: "Exclude kind", opts: Options{ExcludeKinds: []string{"DEPLOYMENT"}}, doc: payments, want: false},
+		{name: "Unknown kind with include", opts: Options{IncludeKinds: []string{"Service"}}, doc: map[string]interface{}{"foo": "bar"}, want: false},
+		{name: "Unknown kind with exclude", opts: Options{ExcludeKinds: []string{"Service"}}, doc: map[string]interface{}{"foo": "bar"}, want: true},
+		{name: "Namespace match", opts: Options{Namespace: "payments"}, doc: payments, want: true},
+		{name: "Namespace mismatch", opts: Options{Namespace: "payments"}, doc: other, want: false},
+		{name: "Namespace missing is kept", opts: Options{Namespace: "payments"}, doc: noNamespace, want: true},
+		{name: "Name glob", opts: Options{Names: []string{"api-*"}}, doc: payments, want: true},
+		{name: "Name glob mismatch", opts: Options{Names: []string{"api-*"}}, doc: cache, want: false},
+		{name: "Selector equality", opts: Options{Selector: "app=payments"}, doc: cache, want: true},
+		{name: "Selector inequality", opts: Options{Selector: "app=payments,tier!=cache"}, doc: cache, want: false},
+		{name: "Selector set-based", opts: Options{Selector: "app in (billing)"}, doc: other, want: true},
+		{name: "Selector without labels", opts: Options{Selector: "app"}, doc: resource("Pod", "", "bare", nil), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.opts)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			if got, err := f.Match(tt.doc); err != nil || got != tt.want {
+				t.Errorf("Match() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilterInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Bad selector", opts: Options{Selector: "app in (payments"}},
+		{name: "Bad name pattern", opts: Options{Names: []string{"api-["}}},
+		{name: "Bad kind token", opts: Options{IncludeKinds: []string{"apps//Deployment"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFilter(tt.opts); err == nil {
+				t.Errorf("NewFilter() expected error")
+			}
+		})
+	}
+}
+
+func TestFilterMatchQualifiedKinds(t *testing.T) {
+	deployment := map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": "web"}}
+	ingressNew := map[string]interface{}{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "metadata": map[string]interface{}{"name": "web"}}
+	ingressOld := map[string]interface{}{"apiVersion": "extensions/v1beta1", "kind": "Ingress", "metadata": map[string]interface{}{"name": "web"}}
+	pod := map[string]interface{}{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{"name": "web"}}
+	cert := map[string]interface{}{"apiVersion": "cert-manager.io/v1", "kind": "Certificate", "metadata": map[string]interface{}{"name": "web"}}
+
+	resolver := func(gr schema.GroupResource) (schema.GroupKind, bool) {
+		if gr.Resource == "cert" || gr.Resource == "certificates" {
+			return schema.GroupKind{Group: "cert-manager.io", Kind: "Certificate"}, true
+		}
+		return schema.GroupKind{}, false
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		doc   interface{}
+		want  bool
+	}{
+		{name: "Short name", token: "deploy", doc: deployment, want: true},
+		{name: "Plural", token: "deployments", doc: deployment, want: true},
+		{name: "Resource dot group", token: "deployments.apps", doc: deployment, want: true},
+		{name: "Group/Kind", token: "apps/Deployment", doc: deployment, want: true},
+		{name: "Group/version/Kind", token: "apps/v1/Deployment", doc: deployment, want: true},
+		{name: "Group/version/Kind wrong version", token: "apps/v1beta2/Deployment", doc: deployment, want: false},
+		{name: "Bare Ingress matches new group", token: "ing", doc: ingressNew, want: true},
+		{name: "Bare Ingress matches old group", token: "ingress", doc: ingressOld, want: true},
+		{name: "Qualified Ingress matches its group", token: "networking.k8s.io/Ingress", doc: ingressNew, want: true},
+		{name: "Qualified Ingress rejects other group", token: "networking.k8s.io/Ingress", doc: ingressOld, want: false},
+		{name: "Core version/Kind", token: "v1/Pod", doc: pod, want: true},
+		{name: "Core group alias", token: "core/v1/pods", doc: pod, want: true},
+		{name: "Core group rejects other groups", token: "core/Deployment", doc: deployment, want: false},
+		{name: "Discovery short name", token: "cert", doc: cert, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(Options{IncludeKinds: []string{tt.token}, KindResolver: resolver})
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			if got, err := f.Match(tt.doc); err != nil || got != tt.want {
+				t.Errorf("Match() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatchWhere(t *testing.T) {
+	deployment := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":        "web",
+			"annotations": map[string]interface{}{"team": "payments"},
+		},
+		"spec": map[string]interface{}{"replicas": 5},
+	}
+	configMap := resource("ConfigMap", "", "settings", nil)
+
+	tests := []struct {
+		name    string
+		expr    string
+		doc     interface{}
+		want    bool
+		wantErr bool
+	}{
+		{name: "Kind and replicas", expr: `object.kind == "Deployment" && object.spec.replicas > 3`, doc: deployment, want: true},
+		{name: "Kind guards missing field", expr: `object.kind == "Deployment" && object.spec.replicas > 3`, doc: configMap, want: false},
+		{name: "Replicas too low", expr: `object.spec.replicas > 10`, doc: deployment, want: false},
+		{name: "Missing field is an error", expr: `object.spec.replicas > 3`, doc: configMap, wantErr: true},
+		{name: "Annotation presence", expr: `has(object.metadata.annotations.team)`, doc: deployment, want: true},
+		{name: "Annotation membership", expr: `"team" in object.metadata.annotations`, doc: deployment, want: true},
+		{name: "Guarded annotation membership", expr: `has(object.metadata.annotations) && "team" in object.metadata.annotations`, doc: configMap, want: false},
+		{name: "Non-bool result", expr: `object.metadata.annotations.team`, doc: deployment, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(Options{Where: tt.expr})
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			got, err := f.Match(tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Match() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, expr := range []string{`object.kind ==`, `size(object.metadata)`, `has(object.metadata.annotations["team"])`} {
+		if _, err := NewFilter(Options{Where: expr}); err == nil {
+			t.Errorf("NewFilter(Where: %q) expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package differ
+
+import "strings"
+
+// IgnoreRule removes a field from resources before they are compared, e.g.
+// an annotation stamped by CI or a replica count owned by an autoscaler.
+type IgnoreRule struct {
+	// Kinds limits the rule to resources of these kinds, in the forms
+	// IncludeKinds accepts. If empty, the rule applies to every resource.
+	Kinds []string
+	// Path is the field's path, one map key per element, e.g.
+	// {"metadata", "annotations", "example.com/build"}. Fields inside lists
+	// can't be addressed.
+	Path []string
+}
+
+// ignorer applies compiled ignore rules.
+type ignorer struct {
+	rules []compiledIgnoreRule
+}
+
+type compiledIgnoreRule struct {
+	kinds []kindSelector
+	path  []string
+}
+
+// newIgnorer compiles the ignore rules of opts. It returns an error if a kind
+// token is malformed.
+func newIgnorer(opts Options) (*ignorer, error) {
+	ig := &ignorer{}
+	for _, rule := range opts.IgnoreFields {
+		if len(rule.Path) == 0 {
+			continue
+		}
+		compiled := compiledIgnoreRule{path: rule.Path}
+		for _, k := range rule.Kinds {
+			sel, err := parseKindSelector(k, opts.KindResolver)
+			if err != nil {
+				return nil, err
+			}
+			compiled.kinds = append(compiled.kinds, sel)
+		}
+		ig.rules = append(ig.rules, compiled)
+	}
+	return ig, nil
+}
+
+// ignoreFields removes the ignored fields from decoded resources in place and
+// returns them.
+func ignoreFields(docs []interface{}, ig *ignorer) []interface{} {
+	if len(ig.rules) == 0 {
+		return docs
+	}
+	for _, obj := range resourceMaps(docs) {
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		kind = strings.ToLower(kind)
+		for _, rule := range ig.rules {
+			if len(rule.kinds) > 0 && !matchesAnyKind(rule.kinds, apiVersion, kind) {
+				continue
+			}
+			removeField(obj, rule.path)
+		}
+	}
+	return docs
+}
+
+// removeField deletes the field at path from obj and reports whether it was
+// there. Maps left empty by the removal are deleted too, so an ignored field
+// doesn't leave an empty parent on one side only.
+func removeField(obj map[string]interface{}, path []string) bool {
+	if len(path) == 1 {
+		_, ok := obj[path[0]]
+		delete(obj, path[0])
+		return ok
+	}
+	child, ok := obj[path[0]].(map[string]interface{})
+	if !ok || !removeField(child, path[1:]) {
+		return false
+	}
+	if len(child) == 0 {
+		delete(obj, path[0])
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package differ
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIgnoreFields(t *testing.T) {
+	doc := func() []interface{} {
+		return []interface{}{
+			map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":        "api",
+					"annotations": map[string]interface{}{"ci/build": "42"},
+					"labels":      map[string]interface{}{},
+				},
+				"spec": map[string]interface{}{"replicas": 3},
+			},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		rules []IgnoreRule
+		want  map[string]interface{}
+	}{
+		{
+			name:  "prunes emptied parents",
+			rules: []IgnoreRule{{Path: []string{"metadata", "annotations", "ci/build"}}},
+			want: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata":   map[string]interface{}{"name": "api", "labels": map[string]interface{}{}},
+				"spec":       map[string]interface{}{"replicas": 3},
+			},
+		},
+		{
+			name:  "keeps empty maps it didn't empty",
+			rules: []IgnoreRule{{Path: []string{"metadata", "labels", "app"}}},
+			want:  doc()[0].(map[string]interface{}),
+		},
+		{
+			name:  "matching kind",
+			rules: []IgnoreRule{{Kinds: []string{"deploy"}, Path: []string{"spec", "replicas"}}},
+			want: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":        "api",
+					"annotations": map[string]interface{}{"ci/build": "42"},
+					"labels":      map[string]interface{}{},
+				},
+			},
+		},
+		{
+			name:  "other kind",
+			rules: []IgnoreRule{{Kinds: []string{"sts"}, Path: []string{"spec", "replicas"}}},
+			want:  doc()[0].(map[string]interface{}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ig, err := newIgnorer(Options{IgnoreFields: tt.rules})
+			if err != nil {
+				t.Fatalf("newIgnorer() error = %v", err)
+			}
+			got := ignoreFields(doc(), ig)
+			if !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("ignoreFields() = %v, want %v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreFieldsInvalidKind(t *testing.T) {
+	if _, err := newIgnorer(Options{IgnoreFields: []IgnoreRule{{Kinds: []string{"a/b/c/d"}, Path: []string{"spec"}}}}); err == nil {
+		t.Error("newIgnorer() with a malformed kind succeeded, want an error")
+	}
+}
@@ -0,0 +1,165 @@
+package differ
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ImmutableChange is a change to a field the API server won't update in
+// place: applying it is rejected, so the resource has to be deleted and
+// recreated.
+type ImmutableChange struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Field is the changed field, e.g. spec.selector.
+	Field string
+	// Reason explains why the change is rejected.
+	Reason string
+}
+
+// immutableRule flags changes to a field of a kind.
+type immutableRule struct {
+	// group is the API group of the kind ("" for the core group).
+	group string
+	kind  string
+	path  []string
+	// when restricts the rule to objects whose previous state matches. Optional.
+	when func(before map[string]interface{}) bool
+	// rejected reports whether changing the field from before to after is
+	// rejected. Both values are set. If nil, any change is rejected.
+	rejected func(before, after interface{}) bool
+	reason   string
+}
+
+// immutableRules are the update restrictions of the built-in kinds that most
+// often force a delete-and-recreate.
+var immutableRules = []immutableRule{
+	{group: "apps", kind: "Deployment", path: []string{"spec", "selector"}, reason: "label selectors can't be changed"},
+	{group: "apps", kind: "ReplicaSet", path: []string{"spec", "selector"}, reason: "label selectors can't be changed"},
+	{group: "apps", kind: "DaemonSet", path: []string{"spec", "selector"}, reason: "label selectors can't be changed"},
+	{group: "apps", kind: "StatefulSet", path: []string{"spec", "selector"}, reason: "label selectors can't be changed"},
+	{group: "apps", kind: "StatefulSet", path: []string{"spec", "volumeClaimTemplates"}, reason: "StatefulSet volume claim templates can't be changed"},
+	{group: "apps", kind: "StatefulSet", path: []string{"spec", "serviceName"}, reason: "the governing Service of a StatefulSet can't be changed"},
+	{group: "apps", kind: "StatefulSet", path: []string{"spec", "podManagementPolicy"}, reason: "the pod management policy of a StatefulSet can't be changed"},
+	{group: "batch", kind: "Job", path: []string{"spec", "selector"}, reason: "label selectors can't be changed"},
+	{group: "batch", kind: "Job", path: []string{"spec", "template"}, reason: "the pod template of a Job can't be changed"},
+	{kind: "Service", path: []string{"spec", "clusterIP"}, rejected: assignedValueChanged, reason: "cluster IPs can't be changed once assigned"},
+	{kind: "PersistentVolumeClaim", path: []string{"spec", "resources", "requests", "storage"}, rejected: quantityDecreased, reason: "volumes can't shrink"},
+	{kind: "PersistentVolumeClaim", path: []string{"spec", "storageClassName"}, reason: "the storage class of a claim can't be changed"},
+	{kind: "PersistentVolumeClaim", path: []string{"spec", "accessModes"}, reason: "the access modes of a claim can't be changed"},
+	{kind: "PersistentVolumeClaim", path: []string{"spec", "volumeMode"}, reason: "the volume mode of a claim can't be changed"},
+	{kind: "ConfigMap", path: []string{"data"}, when: isImmutable, reason: "the ConfigMap is immutable"},
+	{kind: "ConfigMap", path: []string{"binaryData"}, when: isImmutable, reason: "the ConfigMap is immutable"},
+	{kind: "ConfigMap", path: []string{"immutable"}, when: isImmutable, reason: "immutable can't be unset"},
+	{kind: "Secret", path: []string{"data"}, when: isImmutable, reason: "the Secret is immutable"},
+	{kind: "Secret", path: []string{"stringData"}, when: isImmutable, reason: "the Secret is immutable"},
+	{kind: "Secret", path: []string{"immutable"}, when: isImmutable, reason: "immutable can't be unset"},
+}
+
+// ImmutableChanges returns the changes between the resources of two YAML
+// inputs that the API server rejects as updates, such as a new Deployment
+// selector or a shrunk PersistentVolumeClaim. Resources are paired by API
+// group, kind, namespace and name, and filtered like Diff. Only field paths
+// are reported, never values, so the result is safe to print in secure mode.
+func ImmutableChanges(fileA, fileB []byte, opts Options) ([]ImmutableChange, error) {
+	changes, err := Changes(fileA, fileB, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var immutable []ImmutableChange
+	for _, change := range changes {
+		if change.Type == ChangeModified {
+			immutable = append(immutable, immutableChanges(change)...)
+		}
+	}
+	return immutable, nil
+}
+
+// immutableChanges applies the rules of a resource's kind to a change.
+func immutableChanges(change ResourceChange) []ImmutableChange {
+	group := ""
+	if i := strings.Index(change.APIVersion, "/"); i >= 0 {
+		group = change.APIVersion[:i]
+	}
+	before, after := change.Before, change.After
+
+	var changes []ImmutableChange
+	for _, rule := range immutableRules {
+		if rule.group != group || rule.kind != change.Kind {
+			continue
+		}
+		if rule.when != nil && !rule.when(before) {
+			continue
+		}
+		old, inBefore := nestedValue(before, rule.path)
+		cur, inAfter := nestedValue(after, rule.path)
+		if !inBefore && !inAfter {
+			continue
+		}
+		if inBefore && inAfter {
+			if reflect.DeepEqual(old, cur) || (rule.rejected != nil && !rule.rejected(old, cur)) {
+				continue
+			}
+		} else if rule.rejected != nil {
+			// Defaulted or assigned fields may be left out of a manifest.
+			continue
+		}
+		changes = append(changes, ImmutableChange{
+			Kind:      change.Kind,
+			Namespace: change.Namespace,
+			Name:      change.Name,
+			Field:     strings.Join(rule.path, "."),
+			Reason:    rule.reason,
+		})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// nestedValue returns the value at path in obj.
+func nestedValue(obj map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = obj
+	for _, field := range path {
+		m := asStringMap(current)
+		if m == nil {
+			return nil, false
+		}
+		var ok bool
+		if current, ok = m[field]; !ok {
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+// isImmutable reports whether a ConfigMap or Secret is marked immutable.
+func isImmutable(obj map[string]interface{}) bool {
+	immutable, _ := obj["immutable"].(bool)
+	return immutable
+}
+
+// assignedValueChanged reports whether a value assigned by the server was
+// changed to another non-empty value. Clearing it leaves it unchanged.
+func assignedValueChanged(before, after interface{}) bool {
+	old, cur := fmt.Sprint(before), fmt.Sprint(after)
+	return old != "" && cur != "" && old != cur
+}
+
+// quantityDecreased reports whether a resource quantity got smaller.
+func quantityDecreased(before, after interface{}) bool {
+	old, err := apiresource.ParseQuantity(fmt.Sprint(before))
+	if err != nil {
+		return false
+	}
+	cur, err := apiresource.ParseQuantity(fmt.Sprint(after))
+	if err != nil {
+		return false
+	}
+	return cur.Cmp(old) < 0
+}
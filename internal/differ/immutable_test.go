@@ -0,0 +1,81 @@
+package differ
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImmutableChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []string
+	}{
+		{
+			name:   "Deployment selector",
+			before: "apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api}\nspec:\n  selector: {matchLabels: {app: api}}\n  replicas: 1\n",
+			after:  "apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api}\nspec:\n  selector: {matchLabels: {app: api-v2}}\n  replicas: 3\n",
+			want:   []string{"spec.selector"},
+		},
+		{
+			name:   "Deployment replicas only",
+			before: "apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api}\nspec: {replicas: 1}\n",
+			after:  "apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api}\nspec: {replicas: 3}\n",
+		},
+		{
+			name:   "Service clusterIP changed",
+			before: "apiVersion: v1\nkind: Service\nmetadata: {name: api}\nspec: {clusterIP: 10.0.0.1}\n",
+			after:  "apiVersion: v1\nkind: Service\nmetadata: {name: api}\nspec: {clusterIP: 10.0.0.2}\n",
+			want:   []string{"spec.clusterIP"},
+		},
+		{
+			name:   "Service clusterIP left out",
+			before: "apiVersion: v1\nkind: Service\nmetadata: {name: api}\nspec: {clusterIP: 10.0.0.1}\n",
+			after:  "apiVersion: v1\nkind: Service\nmetadata: {name: api}\nspec: {}\n",
+		},
+		{
+			name:   "PVC shrink",
+			before: "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata: {name: data}\nspec: {resources: {requests: {storage: 1Gi}}}\n",
+			after:  "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata: {name: data}\nspec: {resources: {requests: {storage: 500Mi}}}\n",
+			want:   []string{"spec.resources.requests.storage"},
+		},
+		{
+			name:   "PVC expansion",
+			before: "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata: {name: data}\nspec: {resources: {requests: {storage: 1Gi}}}\n",
+			after:  "apiVersion: v1\nkind: PersistentVolumeClaim\nmetadata: {name: data}\nspec: {resources: {requests: {storage: 2Gi}}}\n",
+		},
+		{
+			name:   "Immutable ConfigMap",
+			before: "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: settings}\nimmutable: true\ndata: {mode: fast}\n",
+			after:  "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: settings}\nimmutable: true\ndata: {mode: slow}\n",
+			want:   []string{"data"},
+		},
+		{
+			name:   "Mutable ConfigMap",
+			before: "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: settings}\ndata: {mode: fast}\n",
+			after:  "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: settings}\ndata: {mode: slow}\n",
+		},
+		{
+			name:   "Different resources",
+			before: "apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api}\nspec: {selector: {matchLabels: {app: api}}}\n",
+			after:  "apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: web}\nspec: {selector: {matchLabels: {app: web}}}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := ImmutableChanges([]byte(tt.before), []byte(tt.after), Options{})
+			if err != nil {
+				t.Fatalf("ImmutableChanges() error = %v", err)
+			}
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImmutableChanges() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
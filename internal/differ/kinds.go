@@ -0,0 +1,209 @@
+package differ
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KindResolver resolves a resource name (plural, singular or short name, e.g.
+// "deploy" or "certs"), optionally qualified by group, to a GroupKind.
+// It is typically backed by API discovery.
+type KindResolver func(resource schema.GroupResource) (schema.GroupKind, bool)
+
+// builtinResource describes a built-in resource type for resolving filter tokens
+// without cluster access.
+type builtinResource struct {
+	group      string
+	kind       string
+	plural     string
+	shortNames []string
+}
+
+// builtinResources mirrors the short names and plurals served by a stock API server.
+var builtinResources = []builtinResource{
+	{group: "", kind: "Binding", plural: "bindings"},
+	{group: "", kind: "ComponentStatus", plural: "componentstatuses", shortNames: []string{"cs"}},
+	{group: "", kind: "ConfigMap", plural: "configmaps", shortNames: []string{"cm"}},
+	{group: "", kind: "Endpoints", plural: "endpoints", shortNames: []string{"ep"}},
+	{group: "", kind: "Event", plural: "events", shortNames: []string{"ev"}},
+	{group: "", kind: "LimitRange", plural: "limitranges", shortNames: []string{"limits"}},
+	{group: "", kind: "Namespace", plural: "namespaces", shortNames: []string{"ns"}},
+	{group: "", kind: "Node", plural: "nodes", shortNames: []string{"no"}},
+	{group: "", kind: "PersistentVolumeClaim", plural: "persistentvolumeclaims", shortNames: []string{"pvc"}},
+	{group: "", kind: "PersistentVolume", plural: "persistentvolumes", shortNames: []string{"pv"}},
+	{group: "", kind: "Pod", plural: "pods", shortNames: []string{"po"}},
+	{group: "", kind: "PodTemplate", plural: "podtemplates"},
+	{group: "", kind: "ReplicationController", plural: "replicationcontrollers", shortNames: []string{"rc"}},
+	{group: "", kind: "ResourceQuota", plural: "resourcequotas", shortNames: []string{"quota"}},
+	{group: "", kind: "Secret", plural: "secrets"},
+	{group: "", kind: "ServiceAccount", plural: "serviceaccounts", shortNames: []string{"sa"}},
+	{group: "", kind: "Service", plural: "services", shortNames: []string{"svc"}},
+	{group: "admissionregistration.k8s.io", kind: "MutatingWebhookConfiguration", plural: "mutatingwebhookconfigurations"},
+	{group: "admissionregistration.k8s.io", kind: "ValidatingAdmissionPolicy", plural: "validatingadmissionpolicies"},
+	{group: "admissionregistration.k8s.io", kind: "ValidatingAdmissionPolicyBinding", plural: "validatingadmissionpolicybindings"},
+	{group: "admissionregistration.k8s.io", kind: "ValidatingWebhookConfiguration", plural: "validatingwebhookconfigurations"},
+	{group: "apiextensions.k8s.io", kind: "CustomResourceDefinition", plural: "customresourcedefinitions", shortNames: []string{"crd", "crds"}},
+	{group: "apiregistration.k8s.io", kind: "APIService", plural: "apiservices"},
+	{group: "apps", kind: "ControllerRevision", plural: "controllerrevisions"},
+	{group: "apps", kind: "DaemonSet", plural: "daemonsets", shortNames: []string{"ds"}},
+	{group: "apps", kind: "Deployment", plural: "deployments", shortNames: []string{"deploy"}},
+	{group: "apps", kind: "ReplicaSet", plural: "replicasets", shortNames: []string{"rs"}},
+	{group: "apps", kind: "StatefulSet", plural: "statefulsets", shortNames: []string{"sts"}},
+	{group: "autoscaling", kind: "HorizontalPodAutoscaler", plural: "horizontalpodautoscalers", shortNames: []string{"hpa"}},
+	{group: "batch", kind: "CronJob", plural: "cronjobs", shortNames: []string{"cj"}},
+	{group: "batch", kind: "Job", plural: "jobs"},
+	{group: "certificates.k8s.io", kind: "CertificateSigningRequest", plural: "certificatesigningrequests", shortNames: []string{"csr"}},
+	{group: "coordination.k8s.io", kind: "Lease", plural: "leases"},
+	{group: "discovery.k8s.io", kind: "EndpointSlice", plural: "endpointslices"},
+	{group: "events.k8s.io", kind: "Event", plural: "events", shortNames: []string{"ev"}},
+	{group: "flowcontrol.apiserver.k8s.io", kind: "FlowSchema", plural: "flowschemas"},
+	{group: "flowcontrol.apiserver.k8s.io", kind: "PriorityLevelConfiguration", plural: "prioritylevelconfigurations"},
+	{group: "networking.k8s.io", kind: "IngressClass", plural: "ingressclasses"},
+	{group: "networking.k8s.io", kind: "Ingress", plural: "ingresses", shortNames: []string{"ing"}},
+	{group: "networking.k8s.io", kind: "NetworkPolicy", plural: "networkpolicies", shortNames: []string{"netpol"}},
+	{group: "node.k8s.io", kind: "RuntimeClass", plural: "runtimeclasses"},
+	{group: "policy", kind: "PodDisruptionBudget", plural: "poddisruptionbudgets", shortNames: []string{"pdb"}},
+	{group: "rbac.authorization.k8s.io", kind: "ClusterRoleBinding", plural: "clusterrolebindings"},
+	{group: "rbac.authorization.k8s.io", kind: "ClusterRole", plural: "clusterroles"},
+	{group: "rbac.authorization.k8s.io", kind: "RoleBinding", plural: "rolebindings"},
+	{group: "rbac.authorization.k8s.io", kind: "Role", plural: "roles"},
+	{group: "scheduling.k8s.io", kind: "PriorityClass", plural: "priorityclasses", shortNames: []string{"pc"}},
+	{group: "storage.k8s.io", kind: "CSIDriver", plural: "csidrivers"},
+	{group: "storage.k8s.io", kind: "CSINode", plural: "csinodes"},
+	{group: "storage.k8s.io", kind: "CSIStorageCapacity", plural: "csistoragecapacities"},
+	{group: "storage.k8s.io", kind: "StorageClass", plural: "storageclasses", shortNames: []string{"sc"}},
+	{group: "storage.k8s.io", kind: "VolumeAttachment", plural: "volumeattachments"},
+}
+
+// builtinAliases maps every lowercased kind, plural and short name to the
+// resources it names (several groups may share a name, e.g. events).
+var builtinAliases = func() map[string][]builtinResource {
+	aliases := make(map[string][]builtinResource)
+	for _, r := range builtinResources {
+		names := append([]string{strings.ToLower(r.kind), r.plural}, r.shortNames...)
+		for _, name := range names {
+			aliases[name] = append(aliases[name], r)
+		}
+	}
+	return aliases
+}()
+
+// versionPattern matches Kubernetes API versions such as v1, v2beta1 or v1alpha3.
+var versionPattern = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// kindSelector matches resources by Kind and, optionally, API group and version.
+type kindSelector struct {
+	// kind is lowercased.
+	kind string
+	// group is only compared if hasGroup is set; "" is the core group.
+	group    string
+	hasGroup bool
+	// version is only compared if non-empty.
+	version string
+}
+
+// KindToken is a parsed kind filter token. See ParseKindToken.
+type KindToken struct {
+	// Name is the Kind, plural or short name, as written.
+	Name string
+	// Group is only meaningful if HasGroup is set; "" is the core group.
+	Group    string
+	HasGroup bool
+	// Version is empty unless the token names one.
+	Version string
+}
+
+// ParseKindToken splits a kind filter token into its parts. Supported forms,
+// like kubectl's resource arguments:
+//
+//	Kind, plural or short name   deployment, deployments, deploy
+//	resource.group               deployments.apps, ingresses.networking.k8s.io
+//	group/Kind                   apps/Deployment, networking.k8s.io/Ingress
+//	group/version/Kind           apps/v1/Deployment, core/v1/Pod
+//	version/Kind (core group)    v1/Pod
+func ParseKindToken(token string) (KindToken, error) {
+	token = strings.TrimSpace(token)
+	parts := strings.Split(token, "/")
+	for _, p := range parts {
+		if p == "" {
+			return KindToken{}, fmt.Errorf("invalid kind filter %q", token)
+		}
+	}
+
+	t := KindToken{Name: parts[len(parts)-1]}
+	switch len(parts) {
+	case 1:
+		// resource.group, as in `kubectl get deployments.apps`
+		if r, g, ok := strings.Cut(t.Name, "."); ok {
+			t.Name = r
+			t.Group, t.HasGroup = g, true
+		}
+	case 2:
+		if versionPattern.MatchString(parts[0]) {
+			t.Version = parts[0]
+			t.HasGroup = true
+		} else {
+			t.Group, t.HasGroup = parts[0], true
+		}
+	case 3:
+		t.Group, t.Version, t.HasGroup = parts[0], parts[1], true
+	default:
+		return KindToken{}, fmt.Errorf("invalid kind filter %q: expected Kind, group/Kind or group/version/Kind", token)
+	}
+	if t.Group == "core" {
+		t.Group = ""
+	}
+	return t, nil
+}
+
+// parseKindSelector parses a filter token (see ParseKindToken). Bare names
+// are resolved against the built-in table, then against resolve (if set),
+// and otherwise used as a Kind verbatim.
+func parseKindSelector(token string, resolve KindResolver) (kindSelector, error) {
+	t, err := ParseKindToken(token)
+	if err != nil {
+		return kindSelector{}, err
+	}
+
+	sel := kindSelector{group: t.Group, hasGroup: t.HasGroup, version: t.Version}
+	sel.kind = resolveKind(t.Name, sel, resolve)
+	return sel, nil
+}
+
+// resolveKind maps a Kind, plural or short name to a lowercased Kind.
+func resolveKind(name string, sel kindSelector, resolve KindResolver) string {
+	lower := strings.ToLower(name)
+	for _, r := range builtinAliases[lower] {
+		if !sel.hasGroup || r.group == sel.group {
+			return strings.ToLower(r.kind)
+		}
+	}
+	if resolve != nil {
+		if gk, ok := resolve(schema.GroupResource{Group: sel.group, Resource: lower}); ok {
+			return strings.ToLower(gk.Kind)
+		}
+	}
+	return lower
+}
+
+// matches reports whether a resource with the given apiVersion and
+// lowercased kind is selected.
+func (s kindSelector) matches(apiVersion, kind string) bool {
+	if s.kind != kind {
+		return false
+	}
+	if !s.hasGroup && s.version == "" {
+		return true
+	}
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false
+	}
+	if s.hasGroup && gv.Group != s.group {
+		return false
+	}
+	return s.version == "" || gv.Version == s.version
+}
@@ -2,16 +2,60 @@ package differ
 
 import (
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode/utf8"
 )
 
+// lastAppliedAnnotation is set by `kubectl apply` and holds a JSON copy of the
+// full applied manifest, including any Secret data.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// MaskStrategy selects how a sensitive value is replaced in the output.
+type MaskStrategy string
+
+const (
+	// MaskHash replaces the value with a length-preserving mask ending in a
+	// short hash, so changes stay visible. This is the default.
+	MaskHash MaskStrategy = "hash"
+	// MaskRedact replaces the value with a fixed "<redacted>" marker.
+	MaskRedact MaskStrategy = "redact"
+	// MaskFingerprint replaces the value with a fixed-length hash fingerprint,
+	// disclosing neither content nor length.
+	MaskFingerprint MaskStrategy = "fingerprint"
+	// MaskLength replaces the value with its length, e.g. "<12 bytes>".
+	MaskLength MaskStrategy = "length"
+	// MaskPartial reveals the first and last N characters of the value.
+	MaskPartial MaskStrategy = "partial"
+	// MaskChanged replaces the value with a marker telling whether it differs
+	// from the other side of the diff.
+	MaskChanged MaskStrategy = "changed"
+)
+
+// MaskStrategies lists all supported strategies.
+var MaskStrategies = []MaskStrategy{MaskHash, MaskRedact, MaskFingerprint, MaskLength, MaskPartial, MaskChanged}
+
+// ParseMaskStrategy validates a strategy name (case-insensitive).
+func ParseMaskStrategy(s string) (MaskStrategy, error) {
+	for _, strategy := range MaskStrategies {
+		if strings.EqualFold(s, string(strategy)) {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown mask strategy %q (valid: %v)", s, MaskStrategies)
+}
+
 // MaskConfig defines which fields to mask for a specific Kind.
 type MaskConfig struct {
 	// RootKeys is a list of top-level keys within the resource that contain sensitive data.
 	// Examples: []string{"data", "stringData", "binaryData"}
 	RootKeys []string
+	// Strategy overrides the global mask strategy for this Kind.
+	// If empty, the global strategy is used.
+	Strategy MaskStrategy
 }
 
 // DefaultMaskingRules returns the hardcoded defaults for Secrets and ConfigMaps.
@@ -26,46 +70,144 @@ func DefaultMaskingRules() map[string]MaskConfig {
 	}
 }
 
+// defaultMaskReveal is the number of characters revealed at each end by
+// MaskPartial when Options.MaskReveal is not set.
+const defaultMaskReveal = 4
+
+// masker replaces sensitive values according to the masking rules and strategies.
+type masker struct {
+	rules    map[string]MaskConfig
+	strategy MaskStrategy
+	reveal   int
+	scan     bool
+	// peer holds the original values of the other side of the diff, keyed by
+	// location. It is nil when there is no counterpart to compare against.
+	peer map[string]string
+	// after is true when masking the second (modified) side of a diff.
+	after bool
+	// masked records every original value replaced, for leak checks.
+	masked []maskedValue
+	// unmasked holds the values left in the masked documents, one per line.
+	// Values masked by rule outside Secrets, such as ConfigMap data, are
+	// often ordinary words; one also found here is published by the input
+	// itself, so its appearance in the output isn't a leak.
+	unmasked string
+}
+
+// maskedValue is an original sensitive value and the location it came from.
+type maskedValue struct {
+	loc   string
+	value string
+	// strict is set for Secret data and detected credentials, which are
+	// leaks wherever they appear.
+	strict bool
+}
+
+// newMasker creates a masker for single-sided masking.
+func newMasker(opts Options) *masker {
+	rules := opts.MaskingRules
+	if rules == nil {
+		rules = DefaultMaskingRules()
+	}
+	strategy := opts.MaskStrategy
+	if strategy == "" {
+		strategy = MaskHash
+	}
+	reveal := opts.MaskReveal
+	if reveal <= 0 {
+		reveal = defaultMaskReveal
+	}
+	return &masker{
+		rules:    rules,
+		strategy: strategy,
+		reveal:   reveal,
+		scan:     opts.ScanSecrets,
+	}
+}
+
+// newMaskerPair creates maskers for both sides of a diff. Each one knows the
+// original values of the other side, which MaskChanged needs.
+// It must be called before either side is masked.
+func newMaskerPair(opts Options, docsA, docsB []interface{}) (*masker, *masker) {
+	mkA := newMasker(opts)
+	mkB := newMasker(opts)
+	mkA.peer = collectValues(docsB)
+	mkB.peer = collectValues(docsA)
+	mkB.after = true
+	return mkA, mkB
+}
+
 // maskSensitiveData operates on the documents in-place, masking sensitive fields
-// based on the provided configuration.
-func maskSensitiveData(docs []interface{}, rules map[string]MaskConfig) {
+// based on the masker's rules.
+func maskSensitiveData(docs []interface{}, mk *masker) {
 	for _, doc := range docs {
 		m, ok := doc.(map[string]interface{})
 		if !ok {
-			// Handle generic map if present
-			if _, ok := doc.(map[interface{}]interface{}); ok {
-				// Convert to string map wrapper just for checking kind?
-				// Actually we need to traverse it. simpler to expect map[string]interface{}.
-				// If we encounter generic map, we can try to mask it too if we find Kind.
-				// But deeply nested masking in generic map is tricky.
-				// Let's stick to standard behavior: our loader usually returns map[string]interface{}.
-				continue
-			}
+			// Our loader returns map[string]interface{} for resources; other
+			// shapes (scalars, generic maps) are left untouched.
 			continue
 		}
+		mk.maskDocument(m, resourceLocation(m))
+	}
 
-		kindVal, ok := m["kind"]
-		if !ok {
-			continue
-		}
-		kindStr, ok := kindVal.(string)
-		if !ok {
-			continue
-		}
-		
+	var unmasked strings.Builder
+	for _, v := range collectValues(docs) {
+		unmasked.WriteString(v)
+		unmasked.WriteByte('\n')
+	}
+	mk.unmasked = unmasked.String()
+}
+
+// Mask masks sensitive data in a YAML stream, as secure mode does before
+// diffing, and returns it re-encoded. There is no other side to compare with,
+// so MaskChanged falls back to MaskFingerprint. Like Diff, it refuses to
+// return output in which a masked value survived.
+func Mask(data []byte, opts Options) ([]byte, error) {
+	docs, err := decodeDocs(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	mk := newMasker(opts)
+	maskSensitiveData(docs, mk)
+
+	out, err := marshalDocs(docs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode: %w", err)
+	}
+	if err := verifyMasked(out, mk); err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// maskDocument masks a single resource. base identifies the resource in
+// location keys.
+func (mk *masker) maskDocument(m map[string]interface{}, base string) {
+	mk.maskEmbeddedManifest(m, base)
+
+	if kindStr, ok := m["kind"].(string); ok {
 		normalizedKind := strings.ToLower(kindStr)
-		
+
 		// Check if we have a rule for this Kind
-		if config, exists := rules[normalizedKind]; exists {
+		if config, exists := mk.rules[normalizedKind]; exists {
+			strategy := config.Strategy
+			if strategy == "" {
+				strategy = mk.strategy
+			}
 			for _, rootKey := range config.RootKeys {
-				maskMap(m, rootKey)
+				mk.maskMap(m, rootKey, strategy, base)
 			}
 		}
 	}
+
+	if mk.scan {
+		mk.scanValue(m, "", base)
+	}
 }
 
 // maskMap replaces values in the map found at parent[key] with masked strings.
-func maskMap(parent map[string]interface{}, key string) {
+func (mk *masker) maskMap(parent map[string]interface{}, key string, strategy MaskStrategy, base string) {
 	v, ok := parent[key]
 	if !ok {
 		return
@@ -73,18 +215,217 @@ func maskMap(parent map[string]interface{}, key string) {
 
 	if dataMap, ok := v.(map[string]interface{}); ok {
 		for k, val := range dataMap {
-			parent[key].(map[string]interface{})[k] = generateMask(fmt.Sprintf("%v", val))
+			original := fmt.Sprintf("%v", val)
+			dataMap[k] = mk.mask(original, strategy, locate(base, key, k), original)
 		}
 		return
 	}
-	
+
 	if dataMapGeneric, ok := v.(map[interface{}]interface{}); ok {
 		for k, val := range dataMapGeneric {
-			dataMapGeneric[k] = generateMask(fmt.Sprintf("%v", val))
+			original := fmt.Sprintf("%v", val)
+			dataMapGeneric[k] = mk.mask(original, strategy, locate(base, key, fmt.Sprintf("%v", k)), original)
+		}
+	}
+}
+
+// maskEmbeddedManifest masks the manifest embedded in the last-applied-configuration
+// annotation, if present. The annotation is parsed as JSON and masked recursively
+// with the same rules; if it cannot be parsed it is masked as a whole.
+func (mk *masker) maskEmbeddedManifest(m map[string]interface{}, base string) {
+	annotations := lastAppliedParent(m)
+	if annotations == nil {
+		return
+	}
+	raw, ok := annotations[lastAppliedAnnotation].(string)
+	if !ok || raw == "" {
+		return
+	}
+	loc := locate(base, "metadata", "annotations", lastAppliedAnnotation)
+
+	var embedded map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &embedded); err != nil {
+		annotations[lastAppliedAnnotation] = mk.mask(raw, mk.strategy, loc, raw)
+		return
+	}
+
+	mk.maskDocument(embedded, loc)
+
+	masked, err := json.Marshal(embedded)
+	if err != nil {
+		annotations[lastAppliedAnnotation] = mk.mask(raw, mk.strategy, loc, raw)
+		return
+	}
+	annotations[lastAppliedAnnotation] = string(masked)
+}
+
+// mask replaces value using the given strategy. loc identifies the field the
+// value was found in, and field is the field's full original value (value may
+// be a substring of it); both are used by MaskChanged to find the counterpart.
+// Every value is recorded for leak checks; values from Secrets strictly.
+func (mk *masker) mask(value string, strategy MaskStrategy, loc, field string) string {
+	return mk.replace(value, strategy, loc, field, isSecretLocation(loc))
+}
+
+// maskDetected masks a credential found by the scanner with the global
+// strategy, and records it strictly for leak checks.
+func (mk *masker) maskDetected(value, loc, field string) string {
+	return mk.replace(value, mk.strategy, loc, field, true)
+}
+
+// replace implements mask and maskDetected, recording value for leak checks.
+func (mk *masker) replace(value string, strategy MaskStrategy, loc, field string, strict bool) string {
+	if value == "" {
+		return ""
+	}
+	mk.record(loc, value, strict)
+
+	switch strategy {
+	case MaskRedact:
+		return "<redacted>"
+	case MaskFingerprint:
+		return fingerprint(value)
+	case MaskLength:
+		return fmt.Sprintf("<%d bytes>", len(value))
+	case MaskPartial:
+		// Characters, not bytes, are revealed, so multi-byte UTF-8 values
+		// aren't cut mid-character.
+		runes := []rune(value)
+		if len(runes) <= 2*mk.reveal {
+			return generateMask(value)
+		}
+		return string(runes[:mk.reveal]) + strings.Repeat("*", len(runes)-2*mk.reveal) + string(runes[len(runes)-mk.reveal:])
+	case MaskChanged:
+		if mk.peer == nil {
+			// Nothing to compare against; a fingerprint still reveals whether
+			// values differ without disclosing them.
+			return fingerprint(value)
+		}
+		other, ok := mk.peer[loc]
+		switch {
+		case !ok:
+			return "<set>"
+		case other == field:
+			return "<unchanged>"
+		case mk.after:
+			return "<changed:new>"
+		default:
+			return "<changed:old>"
 		}
+	default:
+		return generateMask(value)
 	}
 }
 
+// record remembers an original value so the rendered output can later be
+// checked for it. Secret data is base64 encoded, so the decoded plaintext is
+// recorded as well.
+func (mk *masker) record(loc, value string, strict bool) {
+	mk.masked = append(mk.masked, maskedValue{loc: loc, value: value, strict: strict})
+
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil && utf8.Valid(decoded) {
+		mk.masked = append(mk.masked, maskedValue{loc: loc, value: string(decoded), strict: strict})
+	}
+}
+
+// lastAppliedParent returns the annotations map of a resource if it carries
+// a last-applied-configuration annotation.
+func lastAppliedParent(m map[string]interface{}) map[string]interface{} {
+	metadata, ok := m["metadata"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if _, ok := annotations[lastAppliedAnnotation]; !ok {
+		return nil
+	}
+	return annotations
+}
+
+// collectValues records the original string form of every scalar value in the
+// documents, keyed by location. Manifests embedded in last-applied-configuration
+// annotations are descended into using the same locations masking uses.
+func collectValues(docs []interface{}) map[string]string {
+	values := make(map[string]string)
+	for _, doc := range docs {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		collectDocument(m, resourceLocation(m), values)
+	}
+	return values
+}
+
+func collectDocument(m map[string]interface{}, base string, values map[string]string) {
+	collectValue(m, base, values)
+
+	annotations := lastAppliedParent(m)
+	if annotations == nil {
+		return
+	}
+	raw, _ := annotations[lastAppliedAnnotation].(string)
+	var embedded map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &embedded); err == nil {
+		collectDocument(embedded, locate(base, "metadata", "annotations", lastAppliedAnnotation), values)
+	}
+}
+
+func collectValue(v interface{}, loc string, values map[string]string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			collectValue(child, locate(loc, k), values)
+		}
+	case map[interface{}]interface{}:
+		for k, child := range val {
+			collectValue(child, locate(loc, fmt.Sprintf("%v", k)), values)
+		}
+	case []interface{}:
+		for i, child := range val {
+			collectValue(child, fmt.Sprintf("%s[%d]", loc, i), values)
+		}
+	case nil:
+	default:
+		values[loc] = fmt.Sprintf("%v", val)
+	}
+}
+
+// resourceLocation identifies a resource for location keys.
+func resourceLocation(m map[string]interface{}) string {
+	kind, _ := m["kind"].(string)
+	var name, namespace string
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		name, _ = metadata["name"].(string)
+		namespace, _ = metadata["namespace"].(string)
+	}
+	return strings.ToLower(kind) + "/" + namespace + "/" + name
+}
+
+// isSecretLocation reports whether a location key lies in a Secret. The
+// manifest embedded in a Secret's last-applied-configuration annotation is
+// located under the Secret too.
+func isSecretLocation(loc string) bool {
+	return strings.HasPrefix(loc, "secret/")
+}
+
+// locate appends path segments to a location key.
+func locate(base string, path ...string) string {
+	for _, p := range path {
+		base += "." + p
+	}
+	return base
+}
+
+// fingerprint returns a short, fixed-length hash of value.
+func fingerprint(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return "<sha256:" + hex.EncodeToString(hash[:])[:12] + ">"
+}
+
 // generateMask returns a string of equal length to input.
 // It uses a hash suffix to preserve uniqueness (so changes are detected)
 // while masking the content.
@@ -93,7 +434,7 @@ func generateMask(original string) string {
 	if length == 0 {
 		return ""
 	}
-	
+
 	// Create a hash of the content
 	hash := sha256.Sum256([]byte(original))
 	hexHash := hex.EncodeToString(hash[:])
@@ -0,0 +1,253 @@
+package differ
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiffMasksLastAppliedConfiguration(t *testing.T) {
+	live := []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"apiVersion":"v1","kind":"Secret","metadata":{"name":"db"},"stringData":{"password":"plaintext-password"}}
+stringData:
+  password: plaintext-password
+`)
+
+	out, err := Diff(live, nil, Options{SecureMode: true})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if strings.Contains(out, "plaintext-password") {
+		t.Errorf("Diff() output leaks secret through annotation:\n%s", out)
+	}
+	if !strings.Contains(out, lastAppliedAnnotation) {
+		t.Errorf("Diff() output dropped the annotation key:\n%s", out)
+	}
+}
+
+func TestMaskEmbeddedManifestUnparsable(t *testing.T) {
+	doc := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				lastAppliedAnnotation: "{not json password=hunter2",
+			},
+		},
+	}
+
+	newMasker(Options{}).maskEmbeddedManifest(doc, "")
+
+	got := doc["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})[lastAppliedAnnotation].(string)
+	if strings.Contains(got, "hunter2") {
+		t.Errorf("maskEmbeddedManifest() = %q, want fully masked", got)
+	}
+}
+
+func TestMaskStrategies(t *testing.T) {
+	const value = "s3cr3t-password"
+	tests := []struct {
+		name     string
+		strategy MaskStrategy
+		want     string
+	}{
+		{name: "Redact", strategy: MaskRedact, want: "<redacted>"},
+		{name: "Length", strategy: MaskLength, want: "<15 bytes>"},
+		{name: "Partial", strategy: MaskPartial, want: "s3cr*******word"},
+		{name: "Hash", strategy: MaskHash, want: generateMask(value)},
+		{name: "Fingerprint", strategy: MaskFingerprint, want: fingerprint(value)},
+		{name: "Changed without counterpart", strategy: MaskChanged, want: fingerprint(value)},
+	}
+	mk := newMasker(Options{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mk.mask(value, tt.strategy, "", value); got != tt.want {
+				t.Errorf("mask() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffChangedStrategy(t *testing.T) {
+	before := []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: old-password
+  username: admin
+`)
+	after := []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: new-password
+  username: admin
+`)
+
+	out, err := Diff(before, after, Options{SecureMode: true, MaskStrategy: MaskChanged})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	for _, want := range []string{"-  password: <changed:old>", "+  password: <changed:new>", "   username: <unchanged>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Diff() output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseMaskStrategy(t *testing.T) {
+	if got, err := ParseMaskStrategy("Redact"); err != nil || got != MaskRedact {
+		t.Errorf("ParseMaskStrategy(Redact) = %q, %v", got, err)
+	}
+	if _, err := ParseMaskStrategy("rot13"); err == nil {
+		t.Errorf("ParseMaskStrategy(rot13) expected error")
+	}
+}
+
+func TestDiffRefusesLeakedSecret(t *testing.T) {
+	// The Secret value is masked, but the same plaintext is also used in an
+	// unmasked Deployment env var, so it would survive rendering.
+	doc := []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: reused-plaintext
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          env:
+            - name: DB_PASSWORD
+              value: reused-plaintext
+`)
+
+	out, err := Diff(nil, doc, Options{SecureMode: true})
+	if !errors.Is(err, ErrSensitiveDataLeak) {
+		t.Fatalf("Diff() error = %v, want ErrSensitiveDataLeak", err)
+	}
+	if out != "" {
+		t.Errorf("Diff() returned output despite leak:\n%s", out)
+	}
+	if strings.Contains(err.Error(), "reused-plaintext") {
+		t.Errorf("Diff() error discloses the value: %v", err)
+	}
+}
+
+func TestMaskIsStable(t *testing.T) {
+	secret := []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  password: cGxhaW50ZXh0LXBhc3N3b3Jk
+`)
+
+	first, err := Mask(secret, Options{})
+	if err != nil {
+		t.Fatalf("Mask() error = %v", err)
+	}
+	if strings.Contains(string(first), "cGxhaW50ZXh0LXBhc3N3b3Jk") {
+		t.Errorf("Mask() output leaks secret:\n%s", first)
+	}
+
+	// Snapshots taken at different times must mask equal values equally.
+	second, err := Mask(secret, Options{})
+	if err != nil {
+		t.Fatalf("Mask() error = %v", err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("Mask() is not deterministic:\n%s\nvs\n%s", first, second)
+	}
+}
+
+func TestDiffAllowsNonSecretValuesElsewhere(t *testing.T) {
+	// ConfigMap values are masked but often repeat elsewhere, like the
+	// namespace here; a Secret value may be part of a longer token.
+	before := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata: {name: cfg, namespace: production}
+data: {ENV: production}
+---
+apiVersion: v1
+kind: Secret
+metadata: {name: db, namespace: production}
+stringData: {password: s3cr3t-pass}
+`)
+	after := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata: {name: cfg, namespace: production}
+data: {ENV: staging}
+---
+apiVersion: v1
+kind: Secret
+metadata: {name: db, namespace: production, labels: {rotated-from: s3cr3t-pass-v1}}
+stringData: {password: s3cr3t-pass}
+`)
+
+	out, err := Diff(before, after, Options{SecureMode: true})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if strings.Contains(out, "staging") {
+		t.Errorf("Diff() shows the masked ConfigMap value:\n%s", out)
+	}
+}
+
+func TestVerifyMaskedChecksRuleValues(t *testing.T) {
+	// A value masked by a user rule in a custom kind is checked for leaks
+	// like Secret data.
+	docs, err := decodeDocs([]byte(`apiVersion: example.com/v1
+kind: Credential
+metadata: {name: api}
+spec: {apiKey: widget-key-1234}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mk := newMasker(Options{MaskingRules: map[string]MaskConfig{"credential": {RootKeys: []string{"spec"}}}})
+	maskSensitiveData(docs, mk)
+
+	if err := verifyMasked("apiKey: widget-key-1234\n", mk); !errors.Is(err, ErrSensitiveDataLeak) {
+		t.Errorf("verifyMasked() error = %v, want ErrSensitiveDataLeak", err)
+	}
+	if err := verifyMasked("apiKey: "+generateMask("widget-key-1234")+"\n", mk); err != nil {
+		t.Errorf("verifyMasked() of the masked output error = %v", err)
+	}
+}
+
+func TestMaskPartialMultiByte(t *testing.T) {
+	mk := newMasker(Options{MaskReveal: 2})
+	if got, want := mk.mask("päss wörd", MaskPartial, "", ""), "pä*****rd"; got != want {
+		t.Errorf("mask() = %q, want %q", got, want)
+	}
+}
+
+func TestContainsToken(t *testing.T) {
+	tests := []struct {
+		output string
+		want   bool
+	}{
+		{"password: hunter2pass\n", true},
+		{`url: "https://admin:hunter2pass@db"`, true},
+		{"hunter2pass", true},
+		{"label: hunter2pass-old", false},
+		{"data: aGVsbG8hunter2pass", false},
+	}
+	for _, tt := range tests {
+		if got := containsToken(tt.output, "hunter2pass"); got != tt.want {
+			t.Errorf("containsToken(%q) = %v, want %v", tt.output, got, tt.want)
+		}
+	}
+}
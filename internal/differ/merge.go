@@ -0,0 +1,349 @@
+package differ
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MergeClass classifies a change in a three-way comparison.
+type MergeClass string
+
+const (
+	// MergeOurs is a change made only on our side.
+	MergeOurs MergeClass = "ours"
+	// MergeTheirs is a change made only on their side.
+	MergeTheirs MergeClass = "theirs"
+	// MergeBoth is the same change made on both sides.
+	MergeBoth MergeClass = "both"
+	// MergeConflict is a field or resource changed differently on each side.
+	MergeConflict MergeClass = "conflict"
+)
+
+// FieldMerge is a field changed on at least one side of a three-way
+// comparison.
+type FieldMerge struct {
+	// Field is the field path, e.g. spec.template.spec.containers[name=api].image.
+	// It is empty for a change to the whole resource: an addition or removal.
+	Field string
+	Class MergeClass
+	// Base, Ours and Theirs are the values on each side, nil where absent.
+	// For whole-resource changes they are the resources. They are masked in
+	// secure mode.
+	Base, Ours, Theirs interface{}
+}
+
+// ResourceMerge is a resource changed on at least one side of a three-way
+// comparison.
+type ResourceMerge struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Fields are the changes, sorted by field.
+	Fields []FieldMerge
+}
+
+// Conflicts returns the number of conflicting changes.
+func (r ResourceMerge) Conflicts() int {
+	n := 0
+	for _, f := range r.Fields {
+		if f.Class == MergeConflict {
+			n++
+		}
+	}
+	return n
+}
+
+// ThreeWay compares two derived YAML inputs, ours and theirs, with their
+// common base, and classifies each change by the side that made it. Resources
+// are paired by API group, kind, namespace and name; filters and ignored
+// fields apply as in Diff. Within a resource, changes are compared per field:
+// objects field by field, lists of named items (containers, env vars, ports)
+// item by item, and other lists whole. Resources changed on neither side are
+// left out.
+//
+// In secure mode, the returned values are masked and checked for leaks;
+// changes are still classified on the original values.
+func ThreeWay(base, ours, theirs []byte, opts Options) ([]ResourceMerge, error) {
+	var docs [3][]interface{}
+	for i, data := range [][]byte{base, ours, theirs} {
+		decoded, err := decodeDocs(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", []string{"base", "ours", "theirs"}[i], err)
+		}
+		docs[i] = decoded
+	}
+
+	filter, err := NewFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := newIgnorer(opts)
+	if err != nil {
+		return nil, err
+	}
+	sides, err := filterResources(filter, docs[0], docs[1], docs[2])
+	if err != nil {
+		return nil, err
+	}
+	var raw, shown [3]map[string]map[string]interface{}
+	for i := range docs {
+		docs[i] = ignoreFields(sides[i], ignore)
+		raw[i] = make(map[string]map[string]interface{})
+		for _, obj := range resourceMaps(docs[i]) {
+			raw[i][resourceKey(obj)] = obj
+		}
+	}
+
+	// Resources are ordered as ours, then theirs, then base.
+	var keys []string
+	seen := make(map[string]bool)
+	for _, i := range []int{1, 2, 0} {
+		for _, obj := range resourceMaps(docs[i]) {
+			if key := resourceKey(obj); !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	// Values are shown from masked copies. Each derived side is masked against
+	// the base, so MaskChanged tells whether it differs from the base.
+	var maskers []*masker
+	shown = raw
+	if opts.SecureMode {
+		var masked [3][]interface{}
+		for i := range docs {
+			masked[i] = deepCopyDocs(docs[i])
+		}
+		baseMasker, oursMasker := newMaskerPair(opts, masked[0], masked[1])
+		_, theirsMasker := newMaskerPair(opts, masked[0], masked[2])
+		maskers = []*masker{baseMasker, oursMasker, theirsMasker}
+		for i, mk := range maskers {
+			maskSensitiveData(masked[i], mk)
+			shown[i] = make(map[string]map[string]interface{})
+			for _, obj := range resourceMaps(masked[i]) {
+				shown[i][resourceKey(obj)] = obj
+			}
+		}
+	}
+
+	var merges []ResourceMerge
+	var values strings.Builder
+	for _, key := range keys {
+		fields := mergeResource(raw[0][key], raw[1][key], raw[2][key], shown[0][key], shown[1][key], shown[2][key])
+		if len(fields) == 0 {
+			continue
+		}
+		obj := raw[1][key]
+		if obj == nil {
+			obj = raw[2][key]
+		}
+		if obj == nil {
+			obj = raw[0][key]
+		}
+		change := newResourceChange(obj)
+		merges = append(merges, ResourceMerge{
+			APIVersion: change.APIVersion,
+			Kind:       change.Kind,
+			Namespace:  change.Namespace,
+			Name:       change.Name,
+			Fields:     fields,
+		})
+		if opts.SecureMode {
+			for _, f := range fields {
+				if f.Field != "" {
+					fmt.Fprintln(&values, FormatValue(f.Base), FormatValue(f.Ours), FormatValue(f.Theirs))
+				}
+			}
+		}
+	}
+
+	if opts.SecureMode {
+		if err := verifyMasked(values.String(), maskers...); err != nil {
+			return nil, err
+		}
+	}
+	return merges, nil
+}
+
+// mergeResource classifies the changes to one resource. The shown objects
+// are the values reported for the raw ones.
+func mergeResource(base, ours, theirs, shownBase, shownOurs, shownTheirs map[string]interface{}) []FieldMerge {
+	whole := func(class MergeClass) []FieldMerge {
+		return []FieldMerge{{Class: class, Base: objectOrNil(shownBase), Ours: objectOrNil(shownOurs), Theirs: objectOrNil(shownTheirs)}}
+	}
+
+	switch {
+	case base == nil && ours == nil:
+		return whole(MergeTheirs)
+	case base == nil && theirs == nil:
+		return whole(MergeOurs)
+	case base == nil && reflect.DeepEqual(ours, theirs):
+		return whole(MergeBoth)
+	case base == nil:
+		// Added on both sides with differences: compare field by field.
+		base, shownBase = map[string]interface{}{}, map[string]interface{}{}
+	case ours == nil && theirs == nil:
+		return whole(MergeBoth)
+	case ours == nil && reflect.DeepEqual(base, theirs):
+		return whole(MergeOurs)
+	case theirs == nil && reflect.DeepEqual(base, ours):
+		return whole(MergeTheirs)
+	case ours == nil || theirs == nil:
+		// Removed on one side and modified on the other.
+		return whole(MergeConflict)
+	}
+
+	flat := [3]map[string]interface{}{flattenFields(base), flattenFields(ours), flattenFields(theirs)}
+	shown := [3]map[string]interface{}{flattenFields(shownBase), flattenFields(shownOurs), flattenFields(shownTheirs)}
+
+	paths := make(map[string]bool)
+	for _, f := range flat {
+		for path := range f {
+			paths[path] = true
+		}
+	}
+
+	var fields []FieldMerge
+	for path := range paths {
+		b, o, t := flat[0][path], flat[1][path], flat[2][path]
+		oursChanged := !reflect.DeepEqual(b, o)
+		theirsChanged := !reflect.DeepEqual(b, t)
+		var class MergeClass
+		switch {
+		case oursChanged && theirsChanged && reflect.DeepEqual(o, t):
+			class = MergeBoth
+		case oursChanged && theirsChanged:
+			class = MergeConflict
+		case oursChanged:
+			class = MergeOurs
+		case theirsChanged:
+			class = MergeTheirs
+		default:
+			continue
+		}
+		fields = append(fields, FieldMerge{Field: path, Class: class, Base: shown[0][path], Ours: shown[1][path], Theirs: shown[2][path]})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+// flattenFields returns the leaf values of an object by field path. Lists of
+// objects with unique names are flattened per item, as name=... elements;
+// other lists are leaves.
+func flattenFields(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if len(val) == 0 {
+				out[path] = val
+				return
+			}
+			for key, child := range val {
+				if path == "" {
+					walk(key, child)
+				} else {
+					walk(path+"."+key, child)
+				}
+			}
+		case []interface{}:
+			names, ok := itemNames(val)
+			if !ok {
+				out[path] = val
+				return
+			}
+			for i, item := range val {
+				walk(fmt.Sprintf("%s[name=%s]", path, names[i]), item)
+			}
+		default:
+			out[path] = val
+		}
+	}
+	walk("", obj)
+	return out
+}
+
+// itemNames returns the names of a non-empty list of objects, if every item
+// has a unique string name.
+func itemNames(items []interface{}) ([]string, bool) {
+	if len(items) == 0 {
+		return nil, false
+	}
+	names := make([]string, len(items))
+	seen := make(map[string]bool, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || seen[name] {
+			return nil, false
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names, true
+}
+
+// FormatValue renders a field value from a ResourceMerge on one line:
+// strings as they are, other values as JSON, and absent values as <absent>.
+func FormatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "<absent>"
+	case string:
+		return val
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
+
+// objectOrNil returns obj as an interface value, or an untyped nil if obj is
+// nil, so absent resources compare equal to nil.
+func objectOrNil(obj map[string]interface{}) interface{} {
+	if obj == nil {
+		return nil
+	}
+	return obj
+}
+
+// deepCopyDocs copies decoded YAML documents, so they can be masked without
+// changing the originals.
+func deepCopyDocs(docs []interface{}) []interface{} {
+	out := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		out[i] = deepCopyValue(doc)
+	}
+	return out
+}
+
+// deepCopyValue copies a decoded YAML value.
+func deepCopyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			out[k] = deepCopyValue(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			out[i] = deepCopyValue(child)
+		}
+		return out
+	default:
+		return val
+	}
+}
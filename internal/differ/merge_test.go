@@ -0,0 +1,190 @@
+package differ
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mergeBase = `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: prod}
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - {name: api, image: "api:1.0"}
+      - {name: proxy, image: "proxy:1"}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings}
+data: {mode: a}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: legacy}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: untouched}
+`
+
+const mergeOurs = `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: prod, labels: {team: web}}
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - {name: api, image: "api:1.1"}
+      - {name: proxy, image: "proxy:2"}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings}
+data: {mode: b}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: untouched}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: ours-only}
+`
+
+const mergeTheirs = `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: prod}
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - {name: proxy, image: "proxy:2"}
+      - {name: api, image: "api:1.0"}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings}
+data: {mode: a}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: legacy}
+data: {kept: "yes"}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: untouched}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: theirs-only}
+`
+
+func TestThreeWay(t *testing.T) {
+	merges, err := ThreeWay([]byte(mergeBase), []byte(mergeOurs), []byte(mergeTheirs), Options{})
+	if err != nil {
+		t.Fatalf("ThreeWay() error = %v", err)
+	}
+
+	got := make(map[string][]string)
+	var order []string
+	for _, m := range merges {
+		order = append(order, m.Kind+" "+qualified(m.Namespace, m.Name))
+		for _, f := range m.Fields {
+			got[m.Name] = append(got[m.Name], string(f.Class)+" "+f.Field)
+		}
+	}
+
+	wantOrder := []string{
+		"Deployment prod/api",
+		"ConfigMap settings",
+		"ConfigMap ours-only",
+		"ConfigMap legacy",
+		"ConfigMap theirs-only",
+	}
+	if !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("resources = %q, want %q", order, wantOrder)
+	}
+
+	want := map[string][]string{
+		"api": {
+			"ours metadata.labels.team",
+			"conflict spec.replicas",
+			"ours spec.template.spec.containers[name=api].image",
+			"both spec.template.spec.containers[name=proxy].image",
+		},
+		"settings":    {"ours data.mode"},
+		"ours-only":   {"ours "},
+		"legacy":      {"conflict "},
+		"theirs-only": {"theirs "},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %q, want %q", got, want)
+	}
+
+	if n := merges[0].Conflicts(); n != 1 {
+		t.Errorf("Conflicts() = %d, want 1", n)
+	}
+	replicas := merges[0].Fields[1]
+	if replicas.Base != 2 || replicas.Ours != 3 || replicas.Theirs != 4 {
+		t.Errorf("spec.replicas = %v/%v/%v, want 2/3/4", replicas.Base, replicas.Ours, replicas.Theirs)
+	}
+}
+
+func TestThreeWayRemovedOnBothSides(t *testing.T) {
+	base := "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: old}\n"
+	merges, err := ThreeWay([]byte(base), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("ThreeWay() error = %v", err)
+	}
+	if len(merges) != 1 || len(merges[0].Fields) != 1 || merges[0].Fields[0].Class != MergeBoth {
+		t.Fatalf("ThreeWay() = %+v, want the removal on both sides", merges)
+	}
+}
+
+func TestThreeWayMasksSecrets(t *testing.T) {
+	secret := "apiVersion: v1\nkind: Secret\nmetadata: {name: db}\ndata: {password: %s}\n"
+	base := strings.Replace(secret, "%s", "aHVudGVyMjIyMjIy", 1)
+	ours := strings.Replace(secret, "%s", "c3dvcmRmaXNoMTIz", 1)
+	theirs := strings.Replace(secret, "%s", "dHJvdWJhZG9yNDU2", 1)
+
+	merges, err := ThreeWay([]byte(base), []byte(ours), []byte(theirs), Options{SecureMode: true})
+	if err != nil {
+		t.Fatalf("ThreeWay() error = %v", err)
+	}
+	if len(merges) != 1 || len(merges[0].Fields) != 1 {
+		t.Fatalf("ThreeWay() = %+v, want one change", merges)
+	}
+	f := merges[0].Fields[0]
+	if f.Field != "data.password" || f.Class != MergeConflict {
+		t.Errorf("change = %s %s, want conflict data.password", f.Class, f.Field)
+	}
+	for _, v := range []interface{}{f.Base, f.Ours, f.Theirs} {
+		if s := FormatValue(v); strings.Contains(s, "aHVudGVy") || strings.Contains(s, "c3dvcmRm") || strings.Contains(s, "dHJvdWJh") {
+			t.Errorf("value %q leaks a password", s)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "<absent>"},
+		{"api:1.0", "api:1.0"},
+		{3, "3"},
+		{[]interface{}{"a", "b"}, `["a","b"]`},
+	}
+	for _, tt := range tests {
+		if got := FormatValue(tt.value); got != tt.want {
+			t.Errorf("FormatValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
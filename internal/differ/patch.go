@@ -0,0 +1,258 @@
+package differ
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// PatchFormat selects the kind of patch Patches generates.
+type PatchFormat string
+
+const (
+	// PatchJSON is an RFC 6902 JSON Patch, a list of operations.
+	PatchJSON PatchFormat = "jsonpatch"
+	// PatchMerge is an RFC 7386 JSON merge patch, where lists are replaced.
+	PatchMerge PatchFormat = "merge"
+	// PatchStrategic is a Kubernetes strategic merge patch, where lists such
+	// as containers are merged by key. It needs the kind's built-in schema.
+	PatchStrategic PatchFormat = "strategic"
+)
+
+// PatchFormats lists all supported patch formats.
+var PatchFormats = []PatchFormat{PatchJSON, PatchMerge, PatchStrategic}
+
+// ParsePatchFormat validates a patch format name (case-insensitive).
+func ParsePatchFormat(s string) (PatchFormat, error) {
+	for _, format := range PatchFormats {
+		if strings.EqualFold(s, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown patch format %q (valid: %v)", s, PatchFormats)
+}
+
+// ResourcePatch is the patch transforming a resource of the first input into
+// its counterpart in the second.
+type ResourcePatch struct {
+	// Change identifies the resource. Its Before and After are masked in
+	// secure mode.
+	Change ResourceChange
+	// Format is the format of Patch. It is PatchMerge for a requested
+	// PatchStrategic if the kind has no built-in schema.
+	Format PatchFormat
+	// Patch is the YAML-encoded patch. It is empty unless the resource was
+	// modified: added and removed resources can't be expressed as patches.
+	Patch []byte
+	// Masked is set in secure mode if Patch carries masked placeholders in
+	// place of changed sensitive values, so applying it would write them.
+	Masked bool
+}
+
+// Patches returns a patch per resource transforming the first YAML input into
+// the second. Resources are paired, filtered, stripped of ignored fields and
+// ordered as by Changes. Merge and strategic merge patches carry the
+// resource's apiVersion, kind, name and namespace, so they can be used as
+// kustomize patches. In secure mode, sensitive values are masked before
+// patching and the patches are checked for leaks like Diff's output.
+func Patches(fileA, fileB []byte, format PatchFormat, opts Options) ([]ResourcePatch, error) {
+	docsA, err := decodeDocs(fileA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode first file: %w", err)
+	}
+	docsB, err := decodeDocs(fileB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode second file: %w", err)
+	}
+
+	filter, err := NewFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := newIgnorer(opts)
+	if err != nil {
+		return nil, err
+	}
+	sides, err := filterResources(filter, docsA, docsB)
+	if err != nil {
+		return nil, err
+	}
+	docsA = ignoreFields(sides[0], ignore)
+	docsB = ignoreFields(sides[1], ignore)
+
+	var maskerA, maskerB *masker
+	// originals are the unmasked pairs, to tell which patches carry masks.
+	var originals []ResourceChange
+	if opts.SecureMode {
+		originals = pairResources(deepCopyDocs(docsA), deepCopyDocs(docsB))
+		maskerA, maskerB = newMaskerPair(opts, docsA, docsB)
+		maskSensitiveData(docsA, maskerA)
+		maskSensitiveData(docsB, maskerB)
+	}
+
+	var patches []ResourcePatch
+	for i, change := range pairResources(docsA, docsB) {
+		if change.Type == ChangeUnchanged {
+			continue
+		}
+		patch := ResourcePatch{Change: change, Format: format}
+		if change.Type == ChangeModified {
+			var value interface{}
+			value, patch.Format, err = createPatch(change, format)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create patch for %s %s: %w", change.Kind, change.Name, err)
+			}
+			if patch.Patch, err = marshalPatch(value); err != nil {
+				return nil, err
+			}
+			if opts.SecureMode {
+				if err := verifyMasked(string(patch.Patch), maskerA, maskerB); err != nil {
+					return nil, err
+				}
+				original, _, err := createPatch(originals[i], format)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create patch for %s %s: %w", change.Kind, change.Name, err)
+				}
+				patch.Masked = !reflect.DeepEqual(original, value)
+			}
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
+
+// createPatch creates the patch of a modified resource, and returns it with
+// the format used.
+func createPatch(change ResourceChange, format PatchFormat) (interface{}, PatchFormat, error) {
+	if format == PatchJSON {
+		return jsonPatchOps(change.Before, change.After, ""), format, nil
+	}
+
+	before, err := json.Marshal(change.Before)
+	if err != nil {
+		return nil, "", err
+	}
+	after, err := json.Marshal(change.After)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var patch []byte
+	dataStruct, err := scheme.Scheme.New(schema.FromAPIVersionAndKind(change.APIVersion, change.Kind))
+	if format == PatchStrategic && err == nil {
+		patch, err = strategicpatch.CreateTwoWayMergePatch(before, after, dataStruct)
+	} else {
+		format = PatchMerge
+		patch, err = jsonpatch.CreateMergePatch(before, after)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var value map[string]interface{}
+	if err := json.Unmarshal(patch, &value); err != nil {
+		return nil, "", err
+	}
+	addIdentity(value, change)
+	return value, format, nil
+}
+
+// addIdentity sets the fields identifying the patched resource in a merge or
+// strategic merge patch.
+func addIdentity(patch map[string]interface{}, change ResourceChange) {
+	patch["apiVersion"] = change.APIVersion
+	patch["kind"] = change.Kind
+	metadata, _ := patch["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		patch["metadata"] = metadata
+	}
+	metadata["name"] = change.Name
+	if change.Namespace != "" {
+		metadata["namespace"] = change.Namespace
+	}
+}
+
+// jsonPatchOps returns the JSON Patch operations transforming before into
+// after, for the values at the JSON pointer path. Object fields are compared
+// in key order; list items are compared by index, with items added or removed
+// at the end.
+func jsonPatchOps(before, after interface{}, path string) []map[string]interface{} {
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(b)+len(a))
+		for key := range b {
+			keys = append(keys, key)
+		}
+		for key := range a {
+			if _, ok := b[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		var ops []map[string]interface{}
+		for _, key := range keys {
+			child := path + "/" + escapePointer(key)
+			beforeValue, inBefore := b[key]
+			afterValue, inAfter := a[key]
+			switch {
+			case !inAfter:
+				ops = append(ops, map[string]interface{}{"op": "remove", "path": child})
+			case !inBefore:
+				ops = append(ops, map[string]interface{}{"op": "add", "path": child, "value": afterValue})
+			default:
+				ops = append(ops, jsonPatchOps(beforeValue, afterValue, child)...)
+			}
+		}
+		return ops
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok {
+			break
+		}
+		var ops []map[string]interface{}
+		for i := 0; i < len(b) && i < len(a); i++ {
+			ops = append(ops, jsonPatchOps(b[i], a[i], path+"/"+strconv.Itoa(i))...)
+		}
+		for i := len(b); i < len(a); i++ {
+			ops = append(ops, map[string]interface{}{"op": "add", "path": path + "/" + strconv.Itoa(i), "value": a[i]})
+		}
+		// Removing from the end keeps the earlier indexes valid.
+		for i := len(b) - 1; i >= len(a); i-- {
+			ops = append(ops, map[string]interface{}{"op": "remove", "path": path + "/" + strconv.Itoa(i)})
+		}
+		return ops
+	}
+	return []map[string]interface{}{{"op": "replace", "path": path, "value": after}}
+}
+
+// escapePointer escapes a key for use in a JSON pointer (RFC 6901).
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// marshalPatch encodes a patch as YAML, with the same indentation as Diff.
+func marshalPatch(patch interface{}) ([]byte, error) {
+	text, err := marshalDocs([]interface{}{patch})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode patch: %w", err)
+	}
+	return []byte(text), nil
+}
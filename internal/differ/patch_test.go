@@ -0,0 +1,117 @@
+package differ
+
+import (
+	"strings"
+	"testing"
+)
+
+const patchBefore = `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: prod}
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - {name: api, image: "api:1.0"}
+      - {name: proxy, image: "proxy:1"}
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w}
+spec: {size: 1, tags: [a]}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: gone}
+`
+
+const patchAfter = `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: prod}
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - {name: api, image: "api:1.1"}
+      - {name: proxy, image: "proxy:1"}
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w}
+spec: {size: 2, tags: [a, b]}
+`
+
+func TestPatches(t *testing.T) {
+	tests := []struct {
+		format PatchFormat
+		want   []string
+	}{
+		{
+			format: PatchStrategic,
+			want: []string{
+				"strategic:apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n  namespace: prod\nspec:\n  template:\n    spec:\n      $setElementOrder/containers:\n        - name: api\n        - name: proxy\n      containers:\n        - image: api:1.1\n          name: api\n",
+				"merge:apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: 2\n  tags:\n    - a\n    - b\n",
+				"strategic:",
+			},
+		},
+		{
+			format: PatchJSON,
+			want: []string{
+				"jsonpatch:- op: replace\n  path: /spec/template/spec/containers/0/image\n  value: api:1.1\n",
+				"jsonpatch:- op: replace\n  path: /spec/size\n  value: 2\n- op: add\n  path: /spec/tags/1\n  value: b\n",
+				"jsonpatch:",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			patches, err := Patches([]byte(patchBefore), []byte(patchAfter), tt.format, Options{})
+			if err != nil {
+				t.Fatalf("Patches() error = %v", err)
+			}
+			if len(patches) != len(tt.want) {
+				t.Fatalf("Patches() returned %d patches, want %d", len(patches), len(tt.want))
+			}
+			for i, p := range patches {
+				if got := string(p.Format) + ":" + string(p.Patch); got != tt.want[i] {
+					t.Errorf("patch %d (%s %s) =\n%s\nwant\n%s", i, p.Change.Type, p.Change.Name, got, tt.want[i])
+				}
+			}
+			if patches[2].Change.Type != ChangeRemoved {
+				t.Errorf("patch 2 type = %s, want %s", patches[2].Change.Type, ChangeRemoved)
+			}
+		})
+	}
+}
+
+func TestPatchesMasksSecrets(t *testing.T) {
+	before := "apiVersion: v1\nkind: Secret\nmetadata: {name: db}\ndata: {password: aHVudGVyMjIyMjIy}\n"
+	after := "apiVersion: v1\nkind: Secret\nmetadata: {name: db}\ndata: {password: c3dvcmRmaXNoMTIz}\n"
+
+	patches, err := Patches([]byte(before), []byte(after), PatchMerge, Options{SecureMode: true})
+	if err != nil {
+		t.Fatalf("Patches() error = %v", err)
+	}
+	if len(patches) != 1 || !strings.Contains(string(patches[0].Patch), "password:") {
+		t.Fatalf("Patches() = %+v, want a patch of the password", patches)
+	}
+	if strings.Contains(string(patches[0].Patch), "c3dvcmRmaXNoMTIz") {
+		t.Errorf("patch leaks the new password:\n%s", patches[0].Patch)
+	}
+	if !patches[0].Masked {
+		t.Error("Patches() didn't flag the patch of a masked value as Masked")
+	}
+
+	// A patch leaving the masked values alone can be applied.
+	after = "apiVersion: v1\nkind: Secret\nmetadata: {name: db, labels: {tier: db}}\ndata: {password: aHVudGVyMjIyMjIy}\n"
+	patches, err = Patches([]byte(before), []byte(after), PatchMerge, Options{SecureMode: true})
+	if err != nil {
+		t.Fatalf("Patches() error = %v", err)
+	}
+	if len(patches) != 1 || patches[0].Masked {
+		t.Errorf("Patches() = %+v, want an unmasked patch of the label", patches)
+	}
+}
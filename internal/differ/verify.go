@@ -0,0 +1,82 @@
+package differ
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSensitiveDataLeak is returned in secure mode when a value that was masked
+// still appears verbatim in the rendered output.
+var ErrSensitiveDataLeak = errors.New("sensitive data leak detected")
+
+// minLeakCheckLength is the shortest value checked for leaks. Shorter values
+// (e.g. "true", "80") occur naturally in manifests and cannot be told apart
+// from a leak.
+const minLeakCheckLength = 6
+
+// verifyMasked checks rendered output against the original values the maskers
+// recorded. If any of them survived as a whole token, the output must not be
+// printed. Values masked by rule outside Secrets are exempt if the masked
+// input of any of the maskers still holds them elsewhere. The error names the
+// value's location, never the value itself.
+func verifyMasked(output string, maskers ...*masker) error {
+	for _, mk := range maskers {
+		if mk == nil {
+			continue
+		}
+		for _, mv := range mk.masked {
+			// Multi-line values are rendered as indented block scalars, so
+			// they are checked line by line.
+			for _, line := range strings.Split(mv.value, "\n") {
+				line = strings.TrimSpace(line)
+				if len(line) < minLeakCheckLength {
+					continue
+				}
+				if containsToken(output, line) && (mv.strict || !unmaskedElsewhere(line, maskers)) {
+					return fmt.Errorf("%w: masked value from %s appears in the rendered output; refusing to print it", ErrSensitiveDataLeak, mv.loc)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// containsToken reports whether value occurs in output as a whole token: not
+// preceded or followed by a character that could extend it, so a value that
+// is part of a longer identifier, word or encoded string doesn't match.
+func containsToken(output, value string) bool {
+	for offset := 0; ; {
+		i := strings.Index(output[offset:], value)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(value)
+		if (start == 0 || !isTokenByte(output[start-1])) && (end == len(output) || !isTokenByte(output[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// isTokenByte reports whether c can be part of an identifier or a base64
+// string.
+func isTokenByte(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return c == '_' || c == '-' || c == '+' || c == '/' || c == '='
+}
+
+// unmaskedElsewhere reports whether value is left unmasked in the input of
+// any of the maskers.
+func unmaskedElsewhere(value string, maskers []*masker) bool {
+	for _, mk := range maskers {
+		if mk != nil && containsToken(mk.unmasked, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+)
+
+// Check is a built-in rule check.
+type Check struct {
+	// Description says what the check flags.
+	Description string
+	run         func(rule Rule, changes []differ.ResourceChange) []violation
+}
+
+// Checks are the built-in checks, by name.
+var Checks = map[string]Check{
+	"replicas-to-zero": {
+		Description: "workloads scaled down to 0 replicas",
+		run:         replicasToZero,
+	},
+	"limits-removed": {
+		Description: "resource limits removed from containers",
+		run:         limitsRemoved,
+	},
+	"latest-image": {
+		Description: "new or changed container images using the latest tag (or no tag)",
+		run:         latestImage,
+	},
+	"privileged-added": {
+		Description: "containers becoming privileged",
+		run:         privilegedAdded,
+	},
+	"service-loadbalancer": {
+		Description: "Services becoming (or added as) type LoadBalancer",
+		run:         serviceLoadBalancer,
+	},
+	"max-deletions": {
+		Description: "more than max resources deleted",
+		run:         maxDeletions,
+	},
+}
+
+func replicasToZero(_ Rule, changes []differ.ResourceChange) []violation {
+	var out []violation
+	for _, change := range changes {
+		if change.Type != differ.ChangeModified {
+			continue
+		}
+		after, ok := intValue(lookup(change.After, "spec", "replicas"))
+		if !ok || after != 0 {
+			continue
+		}
+		// Workloads default to 1 replica.
+		before, ok := intValue(lookup(change.Before, "spec", "replicas"))
+		if !ok {
+			before = 1
+		}
+		if before > 0 {
+			out = append(out, violation{change: change, message: fmt.Sprintf("replicas drop from %d to 0", before)})
+		}
+	}
+	return out
+}
+
+func limitsRemoved(_ Rule, changes []differ.ResourceChange) []violation {
+	var out []violation
+	for _, change := range changes {
+		if change.Type != differ.ChangeModified {
+			continue
+		}
+		beforeContainers := containersByName(change.Before)
+		afterContainers := containersByName(change.After)
+		for _, name := range sortedNames(beforeContainers) {
+			before := beforeContainers[name]
+			after, ok := afterContainers[name]
+			if !ok {
+				continue
+			}
+			limits, _ := lookup(before, "resources", "limits").(map[string]interface{})
+			kept, _ := lookup(after, "resources", "limits").(map[string]interface{})
+			for _, resource := range sortedNames(limits) {
+				if _, ok := kept[resource]; !ok {
+					out = append(out, violation{change: change, message: fmt.Sprintf("container %q: %s limit removed", name, resource)})
+				}
+			}
+		}
+	}
+	return out
+}
+
+func latestImage(_ Rule, changes []differ.ResourceChange) []violation {
+	var out []violation
+	for _, change := range changes {
+		if change.After == nil {
+			continue
+		}
+		before := containersByName(change.Before)
+		after := containersByName(change.After)
+		for _, name := range sortedNames(after) {
+			image, _ := after[name]["image"].(string)
+			if image == "" || !usesLatest(image) {
+				continue
+			}
+			if prev, ok := before[name]; ok && prev["image"] == image {
+				continue
+			}
+			out = append(out, violation{change: change, message: fmt.Sprintf("container %q uses image %q", name, image)})
+		}
+	}
+	return out
+}
+
+func privilegedAdded(_ Rule, changes []differ.ResourceChange) []violation {
+	var out []violation
+	for _, change := range changes {
+		if change.After == nil {
+			continue
+		}
+		before := containersByName(change.Before)
+		after := containersByName(change.After)
+		for _, name := range sortedNames(after) {
+			if lookup(after[name], "securityContext", "privileged") != true {
+				continue
+			}
+			if prev, ok := before[name]; ok && lookup(prev, "securityContext", "privileged") == true {
+				continue
+			}
+			out = append(out, violation{change: change, message: fmt.Sprintf("container %q becomes privileged", name)})
+		}
+	}
+	return out
+}
+
+func serviceLoadBalancer(_ Rule, changes []differ.ResourceChange) []violation {
+	var out []violation
+	for _, change := range changes {
+		if change.Kind != "Service" || change.After == nil {
+			continue
+		}
+		if lookup(change.After, "spec", "type") != "LoadBalancer" {
+			continue
+		}
+		switch before := lookup(change.Before, "spec", "type"); {
+		case change.Before == nil:
+			out = append(out, violation{change: change, message: "new Service of type LoadBalancer"})
+		case before != "LoadBalancer":
+			if before == nil {
+				before = "ClusterIP"
+			}
+			out = append(out, violation{change: change, message: fmt.Sprintf("Service type changes from %v to LoadBalancer", before)})
+		}
+	}
+	return out
+}
+
+func maxDeletions(rule Rule, changes []differ.ResourceChange) []violation {
+	deleted := 0
+	for _, change := range changes {
+		if change.Type == differ.ChangeRemoved {
+			deleted++
+		}
+	}
+	if deleted <= rule.Max {
+		return nil
+	}
+	return []violation{{message: fmt.Sprintf("%d resources deleted (max %d)", deleted, rule.Max)}}
+}
+
+// usesLatest reports whether an image reference resolves to the latest tag:
+// it is tagged latest, or has neither a tag nor a digest.
+func usesLatest(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	// A colon before the last slash belongs to the registry host's port.
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	return i < 0 || name[i+1:] == "latest"
+}
+
+// podSpec returns the pod spec of a workload, Pod or CronJob.
+func podSpec(obj map[string]interface{}) map[string]interface{} {
+	var spec interface{}
+	switch obj["kind"] {
+	case "Pod":
+		spec = lookup(obj, "spec")
+	case "CronJob":
+		spec = lookup(obj, "spec", "jobTemplate", "spec", "template", "spec")
+	default:
+		spec = lookup(obj, "spec", "template", "spec")
+	}
+	m, _ := spec.(map[string]interface{})
+	return m
+}
+
+// containersByName returns the containers and init containers of a resource's
+// pod spec by name.
+func containersByName(obj map[string]interface{}) map[string]map[string]interface{} {
+	containers := make(map[string]map[string]interface{})
+	spec := podSpec(obj)
+	for _, field := range []string{"initContainers", "containers"} {
+		list, _ := spec[field].([]interface{})
+		for _, item := range list {
+			c, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if name, ok := c["name"].(string); ok {
+				containers[name] = c
+			}
+		}
+	}
+	return containers
+}
+
+// lookup returns the value at a path of map fields, or nil.
+func lookup(obj map[string]interface{}, path ...string) interface{} {
+	var current interface{} = obj
+	for _, field := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[field]
+	}
+	return current
+}
+
+// intValue converts a decoded YAML or JSON number to an int64.
+func intValue(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), n == float64(int64(n))
+	default:
+		return 0, false
+	}
+}
+
+// sortedNames returns the keys of a map in order.
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"github.com/google/cel-go/cel"
+)
+
+// expression is a compiled CEL rule expression.
+type expression struct {
+	source  string
+	program cel.Program
+}
+
+// compileExpression compiles a rule expression, which must evaluate to a bool.
+func compileExpression(expr string) (*expression, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("old", cel.DynType),
+		cel.Variable("new", cel.DynType),
+		cel.Variable("change", cel.StringType),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, issues.Err())
+	}
+	if out := ast.OutputType(); out != cel.BoolType && out != cel.DynType {
+		return nil, fmt.Errorf("expression %q must evaluate to bool, not %s", expr, out)
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, err)
+	}
+	return &expression{source: expr, program: program}, nil
+}
+
+// evaluate returns a violation for each change the expression is true for.
+// It fails if the expression can't be evaluated for a change, e.g. because it
+// selects a field the resource doesn't have, or doesn't return a bool, so a
+// typo can't silently disable a guardrail; expressions applied across kinds
+// guard such fields with has() or a check of the kind.
+func (e *expression) evaluate(changes []differ.ResourceChange, message string) ([]violation, error) {
+	if message == "" {
+		message = e.source
+	}
+	var out []violation
+	for _, change := range changes {
+		vars := map[string]interface{}{
+			"old":    nullable(change.Before),
+			"new":    nullable(change.After),
+			"change": string(change.Type),
+		}
+		result, _, err := e.program.Eval(vars)
+		if err != nil {
+			return nil, fmt.Errorf("expression %q failed on %s: %w", e.source, describeChange(change), err)
+		}
+		matched, ok := result.Value().(bool)
+		if !ok {
+			return nil, fmt.Errorf("expression %q returned %s for %s, not a bool", e.source, result.Type().TypeName(), describeChange(change))
+		}
+		if matched {
+			out = append(out, violation{change: change, message: message})
+		}
+	}
+	return out, nil
+}
+
+// describeChange identifies the resource of a change in error messages.
+func describeChange(change differ.ResourceChange) string {
+	if change.Namespace != "" {
+		return change.Kind + " " + change.Namespace + "/" + change.Name
+	}
+	return change.Kind + " " + change.Name
+}
+
+// nullable returns obj, or an untyped nil for CEL's null if obj is nil.
+func nullable(obj map[string]interface{}) interface{} {
+	if obj == nil {
+		return nil
+	}
+	return obj
+}
@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity ranks findings. Runs fail on findings at or above a threshold.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// rank orders severities from least to most severe.
+var rank = map[Severity]int{SeverityInfo: 0, SeverityWarning: 1, SeverityError: 2}
+
+// ParseSeverity parses a severity name.
+func ParseSeverity(s string) (Severity, error) {
+	if _, ok := rank[Severity(s)]; !ok {
+		return "", fmt.Errorf("unknown severity %q (want info, warning or error)", s)
+	}
+	return Severity(s), nil
+}
+
+// AtLeast reports whether s is as severe as threshold.
+func (s Severity) AtLeast(threshold Severity) bool {
+	return rank[s] >= rank[threshold]
+}
+
+// Rule is a guardrail evaluated against the changes between two manifest sets.
+// It runs either a built-in Check or a CEL expression (Expr).
+type Rule struct {
+	// Name identifies the rule in findings.
+	Name string `yaml:"name"`
+	// Severity of the rule's findings. Defaults to error.
+	Severity Severity `yaml:"severity"`
+	// Check is the name of a built-in check, see Checks.
+	Check string `yaml:"check"`
+	// Expr is a CEL expression over the variables "old" and "new" (the
+	// resource before and after, null if absent) and "change" (added, removed
+	// or modified). The rule fails for resources it evaluates to true for, and
+	// the evaluation fails if the expression errors on a resource.
+	Expr string `yaml:"expr"`
+	// Message describes an Expr finding. Defaults to the expression.
+	Message string `yaml:"message"`
+	// Kinds restricts the rule to these kinds, in the forms --include
+	// accepts. If empty, all kinds are checked.
+	Kinds []string `yaml:"kinds"`
+	// Max is the threshold of checks that count, such as max-deletions.
+	Max int `yaml:"max"`
+
+	filter *differ.Filter
+	expr   *expression
+}
+
+// Policy is a set of rules, as loaded from a policy file:
+//
+//	rules:
+//	  - name: no-scale-to-zero
+//	    check: replicas-to-zero
+//	  - name: few-deletions
+//	    check: max-deletions
+//	    max: 5
+//	  - name: no-host-network
+//	    severity: warning
+//	    kinds: [deploy]
+//	    expr: 'new != null && has(new.spec.template.spec.hostNetwork) && new.spec.template.spec.hostNetwork'
+//	    message: pods use the host network
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Finding is a rule violation.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	// Kind, Namespace and Name identify the resource. They are empty for
+	// findings about the change set as a whole, such as max-deletions.
+	Kind      string
+	Namespace string
+	Name      string
+	Message   string
+}
+
+// Load reads and validates a policy file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %w", path, err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse parses and validates a policy. Unknown fields are rejected, so typos
+// don't silently disable a guardrail.
+func Parse(data []byte) (*Policy, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var p Policy
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+	if len(p.Rules) == 0 {
+		return nil, fmt.Errorf("no rules")
+	}
+
+	names := make(map[string]bool, len(p.Rules))
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return &p, nil
+}
+
+// compile validates a rule and prepares its filter and expression.
+func (r *Rule) compile() error {
+	if r.Severity == "" {
+		r.Severity = SeverityError
+	}
+	if _, err := ParseSeverity(string(r.Severity)); err != nil {
+		return err
+	}
+
+	switch {
+	case r.Check != "" && r.Expr != "":
+		return fmt.Errorf("set either check or expr, not both")
+	case r.Check != "":
+		if _, ok := Checks[r.Check]; !ok {
+			return fmt.Errorf("unknown check %q", r.Check)
+		}
+	case r.Expr != "":
+		expr, err := compileExpression(r.Expr)
+		if err != nil {
+			return err
+		}
+		r.expr = expr
+	default:
+		return fmt.Errorf("set check or expr")
+	}
+
+	filter, err := differ.NewFilter(differ.Options{IncludeKinds: r.Kinds})
+	if err != nil {
+		return err
+	}
+	r.filter = filter
+	return nil
+}
+
+// Evaluate runs the rules against a change set. Unchanged resources are not
+// checked. Findings are sorted by severity, most severe first, then in rule
+// order. It fails if a rule's expression can't be evaluated for a resource.
+func (p *Policy) Evaluate(changes []differ.ResourceChange) ([]Finding, error) {
+	var findings []Finding
+	for _, rule := range p.Rules {
+		var matched []differ.ResourceChange
+		for _, change := range changes {
+			if change.Type == differ.ChangeUnchanged {
+				continue
+			}
+			obj := change.After
+			if obj == nil {
+				obj = change.Before
+			}
+			// Kind filters can't fail; only CEL expressions return errors.
+			if ok, _ := rule.filter.Match(obj); ok {
+				matched = append(matched, change)
+			}
+		}
+
+		var violations []violation
+		if rule.expr != nil {
+			var err error
+			if violations, err = rule.expr.evaluate(matched, rule.Message); err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+		} else {
+			violations = Checks[rule.Check].run(rule, matched)
+		}
+		for _, v := range violations {
+			findings = append(findings, Finding{
+				Rule:      rule.Name,
+				Severity:  rule.Severity,
+				Kind:      v.change.Kind,
+				Namespace: v.change.Namespace,
+				Name:      v.change.Name,
+				Message:   v.message,
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return rank[findings[i].Severity] > rank[findings[j].Severity]
+	})
+	return findings, nil
+}
+
+// violation is a rule failure for a resource, or for the change set if the
+// change is zero.
+type violation struct {
+	change  differ.ResourceChange
+	message string
+}
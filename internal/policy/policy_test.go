@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+)
+
+const before = `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: prod}
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: api
+        image: registry.example.com:5000/api:1.2
+        resources:
+          limits: {cpu: 500m, memory: 256Mi}
+---
+apiVersion: v1
+kind: Service
+metadata: {name: api, namespace: prod}
+spec:
+  ports: [{port: 80}]
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: old-a, namespace: prod}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: old-b, namespace: prod}
+`
+
+const after = `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: prod}
+spec:
+  replicas: 0
+  template:
+    spec:
+      containers:
+      - name: api
+        image: registry.example.com:5000/api:latest
+        securityContext: {privileged: true}
+        resources:
+          limits: {cpu: 500m}
+      - name: proxy
+        image: proxy
+---
+apiVersion: v1
+kind: Service
+metadata: {name: api, namespace: prod}
+spec:
+  type: LoadBalancer
+  ports: [{port: 80}]
+`
+
+func TestEvaluate(t *testing.T) {
+	p, err := Parse([]byte(`rules:
+  - name: no-scale-to-zero
+    check: replicas-to-zero
+  - name: keep-limits
+    check: limits-removed
+  - name: pinned-images
+    severity: warning
+    check: latest-image
+  - name: no-privileged
+    check: privileged-added
+  - name: no-load-balancers
+    severity: warning
+    check: service-loadbalancer
+  - name: few-deletions
+    check: max-deletions
+    max: 1
+  - name: no-deleted-configmaps
+    severity: info
+    kinds: [cm]
+    expr: change == "removed"
+    message: ConfigMap deleted
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	changes, err := differ.Changes([]byte(before), []byte(after), differ.Options{})
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+
+	findings, err := p.Evaluate(changes)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, strings.Join([]string{string(f.Severity), f.Rule, f.Kind, f.Name, f.Message}, "|"))
+	}
+	want := []string{
+		"error|no-scale-to-zero|Deployment|api|replicas drop from 3 to 0",
+		"error|keep-limits|Deployment|api|container \"api\": memory limit removed",
+		"error|no-privileged|Deployment|api|container \"api\" becomes privileged",
+		"error|few-deletions|||2 resources deleted (max 1)",
+		"warning|pinned-images|Deployment|api|container \"api\" uses image \"registry.example.com:5000/api:latest\"",
+		"warning|pinned-images|Deployment|api|container \"proxy\" uses image \"proxy\"",
+		"warning|no-load-balancers|Service|api|Service type changes from ClusterIP to LoadBalancer",
+		"info|no-deleted-configmaps|ConfigMap|old-a|ConfigMap deleted",
+		"info|no-deleted-configmaps|ConfigMap|old-b|ConfigMap deleted",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Evaluate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEvaluateExpressionError(t *testing.T) {
+	p, err := Parse([]byte(`rules:
+  - name: typoed
+    kinds: [deploy]
+    expr: new.spec.replica > 5
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	changes, err := differ.Changes([]byte(before), []byte(after), differ.Options{})
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+
+	_, err = p.Evaluate(changes)
+	if err == nil || !strings.Contains(err.Error(), "rule typoed") || !strings.Contains(err.Error(), "Deployment prod/api") {
+		t.Errorf("Evaluate() error = %v, want the failing rule and resource", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{name: "No rules", policy: "rules: []"},
+		{name: "Unknown field", policy: "rules:\n  - name: a\n    check: max-deletions\n    maximum: 3\n"},
+		{name: "Missing name", policy: "rules:\n  - check: max-deletions\n"},
+		{name: "Duplicate name", policy: "rules:\n  - {name: a, check: max-deletions}\n  - {name: a, check: latest-image}\n"},
+		{name: "Unknown check", policy: "rules:\n  - {name: a, check: no-such-check}\n"},
+		{name: "Check and expr", policy: "rules:\n  - {name: a, check: max-deletions, expr: 'true'}\n"},
+		{name: "Invalid expr", policy: "rules:\n  - {name: a, expr: 'new.spec.'}\n"},
+		{name: "Unknown severity", policy: "rules:\n  - {name: a, check: max-deletions, severity: fatal}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.policy)); err == nil {
+				t.Errorf("Parse() error = nil, want an error")
+			}
+		})
+	}
+}
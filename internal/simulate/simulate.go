@@ -0,0 +1,185 @@
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/client-go/applyconfigurations"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// gvkExtension is the OpenAPI extension naming the kinds a schema describes.
+const gvkExtension = "x-kubernetes-group-version-kind"
+
+// Simulator predicts the result of server-side applies without a cluster,
+// using the structured merge rules of the Kubernetes API server: list-map
+// keys, atomic fields and field ownership.
+//
+// Schemas come from OpenAPI files if they describe the kind, else from the
+// built-in types bundled with client-go. Kinds without a schema (custom
+// resources) are merged with a deduced schema, where lists are atomic.
+type Simulator struct {
+	bundled managedfields.TypeConverter
+	files   managedfields.TypeConverter
+	// fileKinds are the kinds described by the OpenAPI files.
+	fileKinds map[schema.GroupVersionKind]bool
+}
+
+// NewSimulator creates a Simulator using the given OpenAPI documents, in
+// addition to the bundled schemas. Both OpenAPI v2 (/openapi/v2) and v3
+// (/openapi/v3/apis/GROUP/VERSION) documents are accepted, as JSON.
+func NewSimulator(openAPIFiles ...string) (*Simulator, error) {
+	s := &Simulator{
+		bundled:   applyconfigurations.NewTypeConverter(scheme.Scheme),
+		fileKinds: make(map[schema.GroupVersionKind]bool),
+	}
+	if len(openAPIFiles) == 0 {
+		return s, nil
+	}
+
+	schemas := make(map[string]*spec.Schema)
+	for _, path := range openAPIFiles {
+		if err := loadOpenAPI(path, schemas); err != nil {
+			return nil, err
+		}
+	}
+	for _, model := range schemas {
+		for _, gvk := range schemaKinds(model) {
+			s.fileKinds[gvk] = true
+		}
+	}
+
+	files, err := managedfields.NewTypeConverter(schemas, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build schemas from OpenAPI: %w", err)
+	}
+	s.files = files
+	return s, nil
+}
+
+// Apply returns the object resulting from a forced server-side apply of
+// local as fieldManager onto live, or onto nothing if live is nil. Like the
+// API server, fields the manager applied before (per live's managedFields)
+// and no longer applies are removed. Defaulting, admission and validation
+// beyond the schema are not simulated.
+func (s *Simulator) Apply(live, local *unstructured.Unstructured, fieldManager string) (*unstructured.Unstructured, error) {
+	gvk := local.GroupVersionKind()
+	if live != nil && live.GroupVersionKind() != gvk {
+		return nil, fmt.Errorf("cannot simulate applying %s onto %s: API version conversion is not supported", gvk, live.GroupVersionKind())
+	}
+
+	var fm *managedfields.FieldManager
+	var err error
+	switch {
+	case s.fileKinds[gvk]:
+		fm, err = managedfields.NewDefaultFieldManager(s.files, noConversion{}, noDefaults{}, creater{}, gvk, gvk.GroupVersion(), "", nil)
+	case scheme.Scheme.Recognizes(gvk):
+		fm, err = managedfields.NewDefaultFieldManager(s.bundled, noConversion{}, noDefaults{}, creater{}, gvk, gvk.GroupVersion(), "", nil)
+	default:
+		fm, err = managedfields.NewDefaultCRDFieldManager(managedfields.NewDeducedTypeConverter(), noConversion{}, noDefaults{}, creater{}, gvk, gvk.GroupVersion(), "", nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var liveObj runtime.Object
+	if live != nil {
+		liveObj = live.DeepCopy()
+	} else {
+		liveObj, _ = creater{}.New(gvk)
+	}
+
+	out, err := fm.Apply(liveObj, local.DeepCopy(), fieldManager, true)
+	if err != nil {
+		return nil, fmt.Errorf("simulated apply failed: %w", err)
+	}
+	result, ok := out.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("simulated apply returned %T", out)
+	}
+	return result, nil
+}
+
+// loadOpenAPI adds the schemas of an OpenAPI v2 or v3 JSON document to schemas.
+func loadOpenAPI(path string, schemas map[string]*spec.Schema) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read OpenAPI file %s: %w", path, err)
+	}
+
+	var doc struct {
+		// Definitions holds the schemas of OpenAPI v2 documents.
+		Definitions map[string]*spec.Schema `json:"definitions"`
+		// Components holds the schemas of OpenAPI v3 documents.
+		Components struct {
+			Schemas map[string]*spec.Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse OpenAPI file %s: %w", path, err)
+	}
+	if len(doc.Definitions) == 0 && len(doc.Components.Schemas) == 0 {
+		return fmt.Errorf("OpenAPI file %s has no schemas", path)
+	}
+
+	for name, model := range doc.Definitions {
+		schemas[name] = model
+	}
+	for name, model := range doc.Components.Schemas {
+		schemas[name] = model
+	}
+	return nil
+}
+
+// schemaKinds returns the kinds a schema describes, from its
+// x-kubernetes-group-version-kind extension.
+func schemaKinds(model *spec.Schema) []schema.GroupVersionKind {
+	entries, _ := model.Extensions[gvkExtension].([]interface{})
+	var gvks []schema.GroupVersionKind
+	for _, entry := range entries {
+		m, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _ := m["group"].(string)
+		version, _ := m["version"].(string)
+		kind, _ := m["kind"].(string)
+		gvks = append(gvks, schema.GroupVersionKind{Group: group, Version: version, Kind: kind})
+	}
+	return gvks
+}
+
+// noConversion is an object converter for a single API version.
+type noConversion struct{}
+
+func (noConversion) Convert(in, out, context interface{}) error {
+	return fmt.Errorf("conversion is not supported")
+}
+
+func (noConversion) ConvertToVersion(in runtime.Object, _ runtime.GroupVersioner) (runtime.Object, error) {
+	return in, nil
+}
+
+func (noConversion) ConvertFieldLabel(_ schema.GroupVersionKind, label, value string) (string, string, error) {
+	return label, value, nil
+}
+
+// noDefaults is an object defaulter that applies no defaults.
+type noDefaults struct{}
+
+func (noDefaults) Default(runtime.Object) {}
+
+// creater creates empty unstructured objects.
+type creater struct{}
+
+func (creater) New(gvk schema.GroupVersionKind) (runtime.Object, error) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetGroupVersionKind(gvk)
+	return obj, nil
+}
@@ -0,0 +1,206 @@
+package simulate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func mustParse(t *testing.T, doc string) *unstructured.Unstructured {
+	t.Helper()
+	data, err := yaml.YAMLToJSON([]byte(doc))
+	if err != nil {
+		t.Fatalf("invalid test manifest: %v", err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		t.Fatalf("invalid test manifest: %v", err)
+	}
+	return obj
+}
+
+func TestApplyMergesListsByKey(t *testing.T) {
+	sim, err := NewSimulator()
+	if err != nil {
+		t.Fatalf("NewSimulator() error = %v", err)
+	}
+
+	live := mustParse(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: default}
+spec:
+  replicas: 2
+  selector: {matchLabels: {app: api}}
+  template:
+    metadata: {labels: {app: api}}
+    spec:
+      containers:
+      - {name: sidecar, image: "proxy:1"}
+      - {name: api, image: "api:1.0"}
+`)
+	local := mustParse(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: api, namespace: default}
+spec:
+  selector: {matchLabels: {app: api}}
+  template:
+    metadata: {labels: {app: api}}
+    spec:
+      containers:
+      - {name: api, image: "api:1.1"}
+`)
+
+	got, err := sim.Apply(live, local, "kubectl")
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	containers, _, _ := unstructured.NestedSlice(got.Object, "spec", "template", "spec", "containers")
+	want := []interface{}{
+		map[string]interface{}{"name": "sidecar", "image": "proxy:1"},
+		map[string]interface{}{"name": "api", "image": "api:1.1"},
+	}
+	if !reflect.DeepEqual(containers, want) {
+		t.Errorf("containers = %v, want %v", containers, want)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(got.Object, "spec", "replicas"); replicas != 2 {
+		t.Errorf("replicas = %d, want 2 (not declared locally)", replicas)
+	}
+}
+
+func TestApplyRemovesFieldsNoLongerApplied(t *testing.T) {
+	sim, err := NewSimulator()
+	if err != nil {
+		t.Fatalf("NewSimulator() error = %v", err)
+	}
+
+	first := mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings, namespace: default}
+data: {mode: fast, level: debug}
+`)
+	live, err := sim.Apply(nil, first, "kubectl")
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	second := mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings, namespace: default}
+data: {mode: fast}
+`)
+	got, err := sim.Apply(live, second, "kubectl")
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	data, _, _ := unstructured.NestedStringMap(got.Object, "data")
+	if want := map[string]string{"mode": "fast"}; !reflect.DeepEqual(data, want) {
+		t.Errorf("data = %v, want %v", data, want)
+	}
+}
+
+func TestApplyWithOpenAPISchema(t *testing.T) {
+	openAPI := `{
+  "components": {"schemas": {
+    "com.example.v1.Widget": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "version": "v1", "kind": "Widget"}],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"type": "object", "x-kubernetes-preserve-unknown-fields": true},
+        "spec": {"type": "object", "properties": {
+          "ports": {
+            "type": "array",
+            "x-kubernetes-list-type": "map",
+            "x-kubernetes-list-map-keys": ["name"],
+            "items": {"type": "object", "properties": {
+              "name": {"type": "string"},
+              "port": {"type": "integer"}
+            }}
+          }
+        }}
+      }
+    }
+  }}
+}`
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	if err := os.WriteFile(path, []byte(openAPI), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	live := mustParse(t, `
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w, namespace: default}
+spec:
+  ports:
+  - {name: http, port: 80}
+  - {name: metrics, port: 9090}
+`)
+	local := mustParse(t, `
+apiVersion: example.com/v1
+kind: Widget
+metadata: {name: w, namespace: default}
+spec:
+  ports:
+  - {name: http, port: 8080}
+`)
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []interface{}
+	}{
+		{
+			name:  "schema merges list items by key",
+			files: []string{path},
+			want: []interface{}{
+				map[string]interface{}{"name": "http", "port": int64(8080)},
+				map[string]interface{}{"name": "metrics", "port": int64(9090)},
+			},
+		},
+		{
+			name: "without schema lists are replaced",
+			want: []interface{}{
+				map[string]interface{}{"name": "http", "port": int64(8080)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim, err := NewSimulator(tt.files...)
+			if err != nil {
+				t.Fatalf("NewSimulator() error = %v", err)
+			}
+			got, err := sim.Apply(live, local, "kubectl")
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			ports, _, _ := unstructured.NestedSlice(got.Object, "spec", "ports")
+			if !reflect.DeepEqual(ports, tt.want) {
+				t.Errorf("ports = %v, want %v", ports, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRejectsVersionChange(t *testing.T) {
+	sim, err := NewSimulator()
+	if err != nil {
+		t.Fatalf("NewSimulator() error = %v", err)
+	}
+	live := mustParse(t, "apiVersion: autoscaling/v1\nkind: HorizontalPodAutoscaler\nmetadata: {name: api}\n")
+	local := mustParse(t, "apiVersion: autoscaling/v2\nkind: HorizontalPodAutoscaler\nmetadata: {name: api}\n")
+	if _, err := sim.Apply(live, local, "kubectl"); err == nil {
+		t.Error("Apply() error = nil, want an error for differing API versions")
+	}
+}
@@ -0,0 +1,28 @@
+// Package kdiff compares Kubernetes resources in memory, the way the kdiff
+// command line tool compares manifest files.
+//
+// Resources are given as unstructured objects (Diff) or as YAML or JSON
+// streams of one or more documents (DiffBytes). They are paired by API group,
+// kind, namespace and name, and the Result holds one Resource per pair: how
+// it changed, the objects on each side, and a unified diff of their YAML.
+//
+// Options narrow the comparison to some resources (WithKinds, WithNamespace,
+// WithSelector, WithWhere, ...), drop fields that are expected to differ
+// (WithIgnoredFields), and mask sensitive values in Secrets, ConfigMaps and,
+// optionally, anywhere they are detected (WithMasking). With masking, the
+// returned diffs are checked for the original Secret values and detected
+// credentials: if one survived rendering, Diff fails with
+// ErrSensitiveDataLeak instead.
+//
+// # Stability
+//
+// kdiff is versioned with semantic versioning, and this package is the only
+// one covered by it; everything under internal/ may change at any time.
+// Within a major version, the exported API of this package is only extended:
+// functions, options, types and struct fields may be added, but existing ones
+// keep their signatures and meaning. Callers should therefore not compare
+// Result or Resource values with == or construct them with unkeyed fields.
+// The exact text of diffs and masked values is not part of the API and may
+// change in minor releases. Until v1.0.0, minor releases may also make
+// incompatible changes, which are called out in the release notes.
+package kdiff
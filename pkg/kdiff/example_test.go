@@ -0,0 +1,129 @@
+package kdiff_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/1azunna/k8s-diff-tool/pkg/kdiff"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func ExampleDiff() {
+	deployment := func(replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "api", "namespace": "prod"},
+			"spec":       map[string]interface{}{"replicas": replicas},
+		}}
+	}
+
+	result, err := kdiff.Diff(
+		[]*unstructured.Unstructured{deployment(2)},
+		[]*unstructured.Unstructured{deployment(3)},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, res := range result.Changed() {
+		replicas, _, _ := unstructured.NestedInt64(res.After.Object, "spec", "replicas")
+		fmt.Printf("%s %s %s/%s: %d replicas\n", res.Change, res.Kind, res.Namespace, res.Name, replicas)
+		fmt.Print(res.Diff)
+	}
+	// Output:
+	// modified Deployment prod/api: 3 replicas
+	// --- Original
+	// +++ Modified
+	// @@ -4,4 +4,4 @@
+	//    name: api
+	//    namespace: prod
+	//  spec:
+	// -  replicas: 2
+	// +  replicas: 3
+}
+
+func ExampleDiffBytes() {
+	before := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings}
+data: {mode: a}
+---
+apiVersion: v1
+kind: Service
+metadata: {name: legacy}
+`)
+	after := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings}
+data: {mode: b}
+---
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+`)
+
+	result, err := kdiff.DiffBytes(before, after, kdiff.WithKinds("svc"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, res := range result.Resources {
+		fmt.Println(res.Change, res.Kind, res.Name)
+	}
+	// Output:
+	// added Service web
+	// removed Service legacy
+}
+
+func ExampleWithIgnoredFields() {
+	before := []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  annotations: {ci.example.com/build: "41"}
+spec: {replicas: 2}
+`)
+	after := []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  annotations: {ci.example.com/build: "42"}
+spec: {replicas: 5}
+`)
+
+	result, err := kdiff.DiffBytes(before, after, kdiff.WithIgnoredFields(
+		kdiff.IgnoreRule{Path: []string{"metadata", "annotations", "ci.example.com/build"}},
+		// The replica count is owned by an autoscaler.
+		kdiff.IgnoreRule{Kinds: []string{"deploy"}, Path: []string{"spec", "replicas"}},
+	))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.HasChanges())
+	// Output:
+	// false
+}
+
+func ExampleWithMasking() {
+	secret := func(password string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "db"},
+			"stringData": map[string]interface{}{"password": password},
+		}}
+	}
+
+	result, err := kdiff.Diff(
+		[]*unstructured.Unstructured{secret("hunter2hunter2")},
+		[]*unstructured.Unstructured{secret("swordfish123")},
+		kdiff.WithMasking(kdiff.MaskLength),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	before, _, _ := unstructured.NestedString(result.Resources[0].Before.Object, "stringData", "password")
+	after, _, _ := unstructured.NestedString(result.Resources[0].After.Object, "stringData", "password")
+	fmt.Println(before, "->", after)
+	// Output:
+	// <14 bytes> -> <12 bytes>
+}
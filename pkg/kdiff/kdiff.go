@@ -0,0 +1,162 @@
+package kdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// ErrSensitiveDataLeak is returned with masking enabled when a value that was
+// masked still appears in a rendered diff.
+var ErrSensitiveDataLeak = differ.ErrSensitiveDataLeak
+
+// ChangeType classifies how a resource differs between the two sides.
+type ChangeType string
+
+const (
+	// Added is a resource only on the after side.
+	Added ChangeType = "added"
+	// Removed is a resource only on the before side.
+	Removed ChangeType = "removed"
+	// Modified is a resource on both sides with different content.
+	Modified ChangeType = "modified"
+	// Unchanged is a resource on both sides with equal content.
+	Unchanged ChangeType = "unchanged"
+)
+
+// Resource is one resource as found on each side of a comparison.
+type Resource struct {
+	Change     ChangeType
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Before is the resource on the before side, nil if it was added. Ignored
+	// fields are removed, and sensitive values masked with WithMasking.
+	Before *unstructured.Unstructured
+	// After is the resource on the after side, nil if it was removed, in the
+	// same form as Before.
+	After *unstructured.Unstructured
+	// Diff is the unified diff of the resource's YAML, empty if it is
+	// unchanged.
+	Diff string
+}
+
+// Result is the outcome of a comparison.
+type Result struct {
+	// Resources are the compared resources, ordered as the after side,
+	// followed by the removed resources in the order of the before side.
+	Resources []Resource
+}
+
+// HasChanges reports whether any resource was added, removed or modified.
+func (r *Result) HasChanges() bool {
+	for _, res := range r.Resources {
+		if res.Change != Unchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed returns the resources that were added, removed or modified.
+func (r *Result) Changed() []Resource {
+	var changed []Resource
+	for _, res := range r.Resources {
+		if res.Change != Unchanged {
+			changed = append(changed, res)
+		}
+	}
+	return changed
+}
+
+// Diff compares two sets of resources. Nil entries are skipped, and neither
+// set is modified.
+func Diff(before, after []*unstructured.Unstructured, opts ...Option) (*Result, error) {
+	dataA, err := encodeResources(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode before resources: %w", err)
+	}
+	dataB, err := encodeResources(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode after resources: %w", err)
+	}
+	return DiffBytes(dataA, dataB, opts...)
+}
+
+// DiffBytes compares two YAML or JSON streams, each holding any number of
+// resources separated by "---" lines. Documents that aren't objects are
+// skipped.
+func DiffBytes(before, after []byte, opts ...Option) (*Result, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	diffOpts, err := o.differOptions()
+	if err != nil {
+		return nil, err
+	}
+	diffs, err := differ.DiffResources(before, after, diffOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Resources: make([]Resource, 0, len(diffs))}
+	for _, d := range diffs {
+		res := Resource{
+			Change:     ChangeType(d.Type),
+			APIVersion: d.APIVersion,
+			Kind:       d.Kind,
+			Namespace:  d.Namespace,
+			Name:       d.Name,
+			Diff:       d.Diff,
+		}
+		if res.Before, err = toUnstructured(d.Before); err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s: %w", d.Kind, d.Name, err)
+		}
+		if res.After, err = toUnstructured(d.After); err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s: %w", d.Kind, d.Name, err)
+		}
+		result.Resources = append(result.Resources, res)
+	}
+	return result, nil
+}
+
+// encodeResources encodes objects as a stream of JSON documents, which the
+// differ decodes as YAML.
+func encodeResources(objs []*unstructured.Unstructured) ([]byte, error) {
+	var docs []string
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(data))
+	}
+	return []byte(strings.Join(docs, "\n---\n")), nil
+}
+
+// toUnstructured converts a decoded YAML object, whose numbers are ints, to
+// an Unstructured with the int64 and float64 numbers its helpers expect.
+func toUnstructured(obj map[string]interface{}) (*unstructured.Unstructured, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	out := &unstructured.Unstructured{}
+	if err := utiljson.Unmarshal(data, &out.Object); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package kdiff
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffLiveObject(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":            "settings",
+			"resourceVersion": "123",
+			"annotations":     map[string]interface{}{"kubectl.kubernetes.io/last-applied-configuration": "{}"},
+		},
+		"data": map[string]interface{}{"retries": "3"},
+	}}
+	local := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "settings"},
+		"data":       map[string]interface{}{"retries": "3"},
+	}}
+	original := live.DeepCopy()
+
+	result, err := Diff([]*unstructured.Unstructured{live, nil}, []*unstructured.Unstructured{local}, WithIgnoredFields(ServerPopulatedFields()...))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if result.HasChanges() {
+		t.Errorf("Diff() = %+v, want no changes", result.Changed())
+	}
+	if !reflect.DeepEqual(live, original) {
+		t.Errorf("Diff() modified its input: %v", live.Object)
+	}
+}
+
+func TestDiffBytesNumbers(t *testing.T) {
+	before := []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata: {name: api}\nspec: {replicas: 2, ratio: 0.5}\n")
+
+	result, err := DiffBytes(before, nil)
+	if err != nil {
+		t.Fatalf("DiffBytes() error = %v", err)
+	}
+	if len(result.Resources) != 1 || result.Resources[0].Change != Removed || result.Resources[0].After != nil {
+		t.Fatalf("DiffBytes() = %+v, want one removed resource", result.Resources)
+	}
+	// Unstructured helpers expect int64 and float64 numbers.
+	spec := result.Resources[0].Before.Object["spec"].(map[string]interface{})
+	if _, ok := spec["replicas"].(int64); !ok {
+		t.Errorf("replicas is a %T, want int64", spec["replicas"])
+	}
+	if _, ok := spec["ratio"].(float64); !ok {
+		t.Errorf("ratio is a %T, want float64", spec["ratio"])
+	}
+}
+
+func TestDiffBytesInvalidOption(t *testing.T) {
+	if _, err := DiffBytes(nil, nil, WithSelector("app in (")); err == nil {
+		t.Error("DiffBytes() with a malformed selector succeeded, want an error")
+	}
+	if _, err := DiffBytes(nil, nil, WithMasking("redcat")); err == nil {
+		t.Error("DiffBytes() with an unknown mask strategy succeeded, want an error")
+	}
+	rules := map[string]MaskRule{"Secret": {Fields: []string{"data"}, Strategy: "hsah"}}
+	if _, err := DiffBytes(nil, nil, WithMasking(MaskRedact), WithMaskRules(rules)); err == nil {
+		t.Error("DiffBytes() with an unknown mask rule strategy succeeded, want an error")
+	}
+}
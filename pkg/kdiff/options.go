@@ -0,0 +1,247 @@
+package kdiff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/1azunna/k8s-diff-tool/internal/cluster"
+	"github.com/1azunna/k8s-diff-tool/internal/differ"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Option configures a comparison.
+type Option func(*options)
+
+// options are the settings of a comparison, gathered from Options.
+type options struct {
+	includeKinds []string
+	excludeKinds []string
+	kindResolver KindResolver
+	namespace    string
+	names        []string
+	selector     string
+	where        string
+	ignoreFields []IgnoreRule
+	masking      bool
+	maskStrategy MaskStrategy
+	maskRules    map[string]MaskRule
+	maskReveal   int
+	scanSecrets  bool
+}
+
+// differOptions translates the settings to the differ's options. It fails
+// for mask strategies other than the MaskStrategy constants.
+func (o *options) differOptions() (differ.Options, error) {
+	opts := differ.Options{
+		IncludeKinds: o.includeKinds,
+		ExcludeKinds: o.excludeKinds,
+		Namespace:    o.namespace,
+		Names:        o.names,
+		Selector:     o.selector,
+		Where:        o.where,
+		SecureMode:   o.masking,
+		MaskReveal:   o.maskReveal,
+		ScanSecrets:  o.scanSecrets,
+	}
+	if o.kindResolver != nil {
+		opts.KindResolver = differ.KindResolver(o.kindResolver)
+	}
+	for _, rule := range o.ignoreFields {
+		opts.IgnoreFields = append(opts.IgnoreFields, differ.IgnoreRule{Kinds: rule.Kinds, Path: rule.Path})
+	}
+
+	var err error
+	if opts.MaskStrategy, err = parseMaskStrategy(o.maskStrategy); err != nil {
+		return differ.Options{}, err
+	}
+	if o.maskRules != nil {
+		opts.MaskingRules = make(map[string]differ.MaskConfig, len(o.maskRules))
+		for kind, rule := range o.maskRules {
+			strategy, err := parseMaskStrategy(rule.Strategy)
+			if err != nil {
+				return differ.Options{}, fmt.Errorf("mask rule for %s: %w", kind, err)
+			}
+			opts.MaskingRules[strings.ToLower(kind)] = differ.MaskConfig{
+				RootKeys: rule.Fields,
+				Strategy: strategy,
+			}
+		}
+	}
+	return opts, nil
+}
+
+// parseMaskStrategy validates a strategy, which may be empty for the default.
+func parseMaskStrategy(strategy MaskStrategy) (differ.MaskStrategy, error) {
+	if strategy == "" {
+		return "", nil
+	}
+	return differ.ParseMaskStrategy(string(strategy))
+}
+
+// KindResolver resolves a resource name (plural, singular or short name, e.g.
+// "deploy" or "certs"), optionally qualified by group, to a GroupKind. It is
+// typically backed by API discovery, so kind filters can use the short names
+// of custom resources.
+type KindResolver func(resource schema.GroupResource) (schema.GroupKind, bool)
+
+// MaskStrategy selects how a sensitive value is replaced.
+type MaskStrategy string
+
+const (
+	// MaskHash replaces the value with a length-preserving mask ending in a
+	// short hash, so changes stay visible. This is the default.
+	MaskHash MaskStrategy = "hash"
+	// MaskRedact replaces the value with a fixed "<redacted>" marker.
+	MaskRedact MaskStrategy = "redact"
+	// MaskFingerprint replaces the value with a fixed-length hash fingerprint,
+	// disclosing neither content nor length.
+	MaskFingerprint MaskStrategy = "fingerprint"
+	// MaskLength replaces the value with its length, e.g. "<12 bytes>".
+	MaskLength MaskStrategy = "length"
+	// MaskPartial reveals the first and last characters of the value (see
+	// WithMaskReveal).
+	MaskPartial MaskStrategy = "partial"
+	// MaskChanged replaces the value with a marker telling whether it differs
+	// from the other side.
+	MaskChanged MaskStrategy = "changed"
+)
+
+// MaskRule selects the fields masked in resources of one kind.
+type MaskRule struct {
+	// Fields are the top-level fields holding sensitive values, e.g. "data".
+	// Every value below them is masked.
+	Fields []string
+	// Strategy overrides the strategy given to WithMasking for this kind.
+	Strategy MaskStrategy
+}
+
+// IgnoreRule removes a field from resources before they are compared.
+type IgnoreRule struct {
+	// Kinds limits the rule to resources of these kinds, in the forms
+	// WithKinds accepts. If empty, the rule applies to every resource.
+	Kinds []string
+	// Path is the field's path, one map key per element, e.g.
+	// {"metadata", "annotations", "example.com/build"}. Fields inside lists
+	// can't be addressed.
+	Path []string
+}
+
+// ServerPopulatedFields returns ignore rules for the fields the API server
+// sets on live objects (metadata.managedFields, metadata.resourceVersion,
+// status, ...), for comparing live objects with manifests.
+func ServerPopulatedFields() []IgnoreRule {
+	var rules []IgnoreRule
+	for _, path := range cluster.ServerPopulatedFields {
+		rules = append(rules, IgnoreRule{Path: path})
+	}
+	for _, key := range cluster.ServerPopulatedAnnotations {
+		rules = append(rules, IgnoreRule{Path: []string{"metadata", "annotations", key}})
+	}
+	return rules
+}
+
+// WithKinds keeps only resources of the given kinds. Kinds are matched
+// case-insensitively and may be given as plurals or short names ("deploy"),
+// and qualified as group/Kind or group/version/Kind.
+func WithKinds(kinds ...string) Option {
+	return func(o *options) {
+		o.includeKinds = append(o.includeKinds, kinds...)
+	}
+}
+
+// WithoutKinds drops resources of the given kinds, in the forms WithKinds
+// accepts.
+func WithoutKinds(kinds ...string) Option {
+	return func(o *options) {
+		o.excludeKinds = append(o.excludeKinds, kinds...)
+	}
+}
+
+// WithKindResolver resolves kinds that the built-in table doesn't know, e.g.
+// short names of custom resources.
+func WithKindResolver(resolve KindResolver) Option {
+	return func(o *options) {
+		o.kindResolver = resolve
+	}
+}
+
+// WithNamespace keeps only resources in namespace. Resources without a
+// namespace are kept, since they are cluster-scoped or take their namespace
+// when applied.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithNames keeps only resources whose name matches one of the glob patterns,
+// e.g. "api-*".
+func WithNames(patterns ...string) Option {
+	return func(o *options) {
+		o.names = append(o.names, patterns...)
+	}
+}
+
+// WithSelector keeps only resources matching a label selector, e.g.
+// "app=payments,tier!=cache". Like the other filters, it keeps a resource on
+// both sides if it matches on either.
+func WithSelector(selector string) Option {
+	return func(o *options) {
+		o.selector = selector
+	}
+}
+
+// WithWhere keeps only resources for which a CEL expression over the
+// variable "object" is true, e.g. `object.kind == "Deployment" &&
+// object.spec.replicas > 3`. The expression must return a bool; if it fails
+// on a resource, e.g. by selecting a missing field, the comparison fails.
+func WithWhere(expr string) Option {
+	return func(o *options) {
+		o.where = expr
+	}
+}
+
+// WithIgnoredFields removes fields from the selected resources before they
+// are compared.
+func WithIgnoredFields(rules ...IgnoreRule) Option {
+	return func(o *options) {
+		o.ignoreFields = append(o.ignoreFields, rules...)
+	}
+}
+
+// WithMasking masks sensitive values: the data of Secrets and ConfigMaps, or
+// the fields selected by WithMaskRules, and credentials embedded in the
+// kubectl last-applied-configuration annotation. An empty strategy means
+// MaskHash; Diff and DiffBytes fail for strategies other than the MaskStrategy
+// constants.
+func WithMasking(strategy MaskStrategy) Option {
+	return func(o *options) {
+		o.masking = true
+		o.maskStrategy = strategy
+	}
+}
+
+// WithMaskRules replaces the default masking rules, by kind. Kinds are
+// matched case-insensitively. It only takes effect with WithMasking.
+func WithMaskRules(rules map[string]MaskRule) Option {
+	return func(o *options) {
+		o.maskRules = rules
+	}
+}
+
+// WithMaskReveal sets the number of characters MaskPartial reveals at each
+// end of a value (default 4).
+func WithMaskReveal(n int) Option {
+	return func(o *options) {
+		o.maskReveal = n
+	}
+}
+
+// WithSecretScanning also masks credentials detected in any string value of
+// any resource: known token formats and high-entropy strings. It only takes
+// effect with WithMasking.
+func WithSecretScanning() Option {
+	return func(o *options) {
+		o.scanSecrets = true
+	}
+}